@@ -0,0 +1,45 @@
+#include <stdlib.h>
+
+// The last error is kept per thread so that concurrent callers never see
+// each other's failures.
+static __thread char* lastErrorMessage;
+static __thread char* lastErrorSource;
+static __thread int lastErrorLine;
+static __thread int lastErrorColumn;
+static __thread long long lastErrorOffset;
+static __thread int lastErrorField;
+
+void setLastError(char* message, char* source, int line, int column, long long offset, int field) {
+    free(lastErrorMessage);
+    free(lastErrorSource);
+    lastErrorMessage = message;
+    lastErrorSource = source;
+    lastErrorLine = line;
+    lastErrorColumn = column;
+    lastErrorOffset = offset;
+    lastErrorField = field;
+}
+
+char* getLastErrorMessage(void) {
+    return lastErrorMessage;
+}
+
+char* getLastErrorSource(void) {
+    return lastErrorSource;
+}
+
+int getLastErrorLine(void) {
+    return lastErrorLine;
+}
+
+int getLastErrorColumn(void) {
+    return lastErrorColumn;
+}
+
+long long getLastErrorOffset(void) {
+    return lastErrorOffset;
+}
+
+int getLastErrorField(void) {
+    return lastErrorField;
+}
@@ -1,32 +1,368 @@
 package main
 
+/*
+#cgo CFLAGS: -I${SRCDIR}/../..
+#include <stdint.h>
+#include <stdlib.h>
+
+// libcsv.h defines the types of the C API. Its prototypes are left out
+// because cgo declares the exported functions itself.
+#define LIBCSV_TYPES_ONLY
+#include "libcsv.h"
+
+void callRejectCallback(CsvRejectCallback callback, int line, const char* reason, const char** fields, int numFields, void* userData);
+*/
 import "C"
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"errors"
 	"milenio.capital/code-challenge/pkg/csv"
 	"os"
+	"runtime/cgo"
+	"strings"
+	"unsafe"
 )
 
 func main() {}
 
 //export processCsv
-func processCsv(csvData *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char) {
+func processCsv(csvData *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char) (status C.int) {
+	defer recoverPanic(&status)
 	goCsv := C.GoString(csvData)
 	goSelectedColumns := C.GoString(selectedColumns)
 	goRowFilterDefinitions := C.GoString(rowFilterDefinitions)
 	err := csv.ProcessCsv(goCsv, goSelectedColumns, goRowFilterDefinitions)
-	if err != nil {
-		_, _ = fmt.Fprintln(os.Stderr, err)
-	}
+	return reportError(err)
 }
 
 //export processCsvFile
-func processCsvFile(csvFilePath *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char) {
+func processCsvFile(csvFilePath *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char) (status C.int) {
+	defer recoverPanic(&status)
 	goCsvFilePath := C.GoString(csvFilePath)
 	goSelectedColumns := C.GoString(selectedColumns)
 	goRowFilterDefinitions := C.GoString(rowFilterDefinitions)
 	err := csv.ProcessCsvFile(goCsvFilePath, goSelectedColumns, goRowFilterDefinitions)
+	return reportError(err)
+}
+
+//export processCsvToBuffer
+func processCsvToBuffer(csvData *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	var buf bytes.Buffer
+	err := csv.Process(context.Background(), strings.NewReader(C.GoString(csvData)), &buf, csv.Options{
+		SelectedColumns:      C.GoString(selectedColumns),
+		RowFilterDefinitions: C.GoString(rowFilterDefinitions),
+	})
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export processCsvFileToBuffer
+func processCsvFileToBuffer(csvFilePath *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	var buf bytes.Buffer
+	err := csv.ProcessFile(context.Background(), C.GoString(csvFilePath), &buf, csv.Options{
+		SelectedColumns:      C.GoString(selectedColumns),
+		RowFilterDefinitions: C.GoString(rowFilterDefinitions),
+	})
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export processCsvToBufferWithDialect
+func processCsvToBufferWithDialect(csvData *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char, inputDialect *C.CsvDialect, outputDialect *C.CsvDialect, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	var buf bytes.Buffer
+	err := csv.Process(context.Background(), strings.NewReader(C.GoString(csvData)), &buf, dialectOptions(selectedColumns, rowFilterDefinitions, inputDialect, outputDialect))
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export processCsvFileToBufferWithDialect
+func processCsvFileToBufferWithDialect(csvFilePath *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char, inputDialect *C.CsvDialect, outputDialect *C.CsvDialect, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	var buf bytes.Buffer
+	err := csv.ProcessFile(context.Background(), C.GoString(csvFilePath), &buf, dialectOptions(selectedColumns, rowFilterDefinitions, inputDialect, outputDialect))
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export processCsvFileAuto
+func processCsvFileAuto(csvFilePath *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char) (status C.int) {
+	defer recoverPanic(&status)
+	err := csv.ProcessFile(context.Background(), C.GoString(csvFilePath), os.Stdout, csv.Options{
+		SelectedColumns:      C.GoString(selectedColumns),
+		RowFilterDefinitions: C.GoString(rowFilterDefinitions),
+		DetectDialect:        true,
+	})
+	return reportError(err)
+}
+
+//export processCsvToBufferLenient
+func processCsvToBufferLenient(csvData *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char, rejectOptions *C.CsvRejectOptions, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	opts, closeRejects, err := lenientOptions(selectedColumns, rowFilterDefinitions, rejectOptions)
+	if err != nil {
+		return reportError(err)
+	}
+	var buf bytes.Buffer
+	err = csv.Process(context.Background(), strings.NewReader(C.GoString(csvData)), &buf, opts)
+	if closeErr := closeRejects(); err == nil {
+		err = closeErr
+	}
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export processCsvFileToBufferLenient
+func processCsvFileToBufferLenient(csvFilePath *C.char, selectedColumns *C.char, rowFilterDefinitions *C.char, rejectOptions *C.CsvRejectOptions, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	opts, closeRejects, err := lenientOptions(selectedColumns, rowFilterDefinitions, rejectOptions)
+	if err != nil {
+		return reportError(err)
+	}
+	var buf bytes.Buffer
+	err = csv.ProcessFile(context.Background(), C.GoString(csvFilePath), &buf, opts)
+	if closeErr := closeRejects(); err == nil {
+		err = closeErr
+	}
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export csvPrepareQuery
+func csvPrepareQuery(columns **C.char, numColumns C.int, selectedColumns *C.char, rowFilterDefinitions *C.char, query **C.CsvQuery) (status C.int) {
+	defer recoverPanic(&status)
+	if query == nil {
+		return reportError(errors.New("query must not be NULL"))
+	}
+	*query = nil
+	header := make([]string, numColumns)
+	for i, column := range unsafe.Slice(columns, numColumns) {
+		header[i] = C.GoString(column)
+	}
+	q, err := csv.Prepare(header, csv.Options{
+		SelectedColumns:      C.GoString(selectedColumns),
+		RowFilterDefinitions: C.GoString(rowFilterDefinitions),
+	})
+	if err != nil {
+		return reportError(err)
+	}
+	handle := (*C.uintptr_t)(C.malloc(C.size_t(unsafe.Sizeof(C.uintptr_t(0)))))
+	*handle = C.uintptr_t(cgo.NewHandle(q))
+	*query = (*C.CsvQuery)(unsafe.Pointer(handle))
+	return reportError(nil)
+}
+
+//export csvQueryRunToBuffer
+func csvQueryRunToBuffer(query *C.CsvQuery, csvData *C.char, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	q, err := goQuery(query)
+	if err != nil {
+		return reportError(err)
+	}
+	var buf bytes.Buffer
+	_, err = q.Run(context.Background(), strings.NewReader(C.GoString(csvData)), &buf)
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export csvQueryRunFileToBuffer
+func csvQueryRunFileToBuffer(query *C.CsvQuery, csvFilePath *C.char, output **C.char, outputLength *C.size_t) (status C.int) {
+	defer recoverPanic(&status)
+	clearBuffer(output, outputLength)
+	q, err := goQuery(query)
+	if err != nil {
+		return reportError(err)
+	}
+	var buf bytes.Buffer
+	_, err = q.RunFile(context.Background(), C.GoString(csvFilePath), &buf)
+	return exportBuffer(buf.Bytes(), err, output, outputLength)
+}
+
+//export freeCsvQuery
+func freeCsvQuery(query *C.CsvQuery) {
+	defer recoverPanic(nil)
+	if query == nil {
+		return
+	}
+	handle := (*C.uintptr_t)(unsafe.Pointer(query))
+	cgo.Handle(*handle).Delete()
+	C.free(unsafe.Pointer(query))
+}
+
+//export csvSniff
+func csvSniff(csvData *C.char, result *C.CsvSniffResult) (status C.int) {
+	defer recoverPanic(&status)
+	sniffed, err := csv.Sniff(strings.NewReader(C.GoString(csvData)))
+	return exportSniffResult(sniffed, err, result)
+}
+
+//export csvSniffFile
+func csvSniffFile(csvFilePath *C.char, result *C.CsvSniffResult) (status C.int) {
+	defer recoverPanic(&status)
+	file, err := os.Open(C.GoString(csvFilePath))
 	if err != nil {
-		_, _ = fmt.Fprintln(os.Stderr, err)
+		return exportSniffResult(csv.SniffResult{}, err, result)
+	}
+	defer func() { _ = file.Close() }()
+	sniffed, err := csv.Sniff(file)
+	return exportSniffResult(sniffed, err, result)
+}
+
+//export freeCsvSniffResult
+func freeCsvSniffResult(result *C.CsvSniffResult) {
+	if result == nil {
+		return
+	}
+	C.free(unsafe.Pointer(result.dialect.lineTerminator))
+	C.free(unsafe.Pointer(result.encoding))
+	C.free(unsafe.Pointer(result.columnTypes))
+	*result = C.CsvSniffResult{}
+}
+
+//export freeCsvBuffer
+func freeCsvBuffer(buffer *C.char) {
+	C.free(unsafe.Pointer(buffer))
+}
+
+// clearBuffer resets the output parameters so that a failed call always
+// leaves *output NULL, even if it panics.
+func clearBuffer(output **C.char, outputLength *C.size_t) {
+	if output != nil {
+		*output = nil
+	}
+	if outputLength != nil {
+		*outputLength = 0
+	}
+}
+
+// exportBuffer hands data to the C caller as a NUL-terminated, malloc'd
+// buffer. On error nothing is allocated.
+func exportBuffer(data []byte, err error, output **C.char, outputLength *C.size_t) C.int {
+	if err != nil {
+		return reportError(err)
+	}
+	if output == nil {
+		return reportError(nil)
+	}
+	buffer := (*C.char)(C.malloc(C.size_t(len(data) + 1)))
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(buffer)), len(data)+1), append(data, 0))
+	*output = buffer
+	if outputLength != nil {
+		*outputLength = C.size_t(len(data))
+	}
+	return reportError(nil)
+}
+
+// dialectOptions builds the options of the WithDialect functions. A NULL
+// input dialect is detected from the data and a NULL output dialect is
+// RFC 4180.
+func dialectOptions(selectedColumns *C.char, rowFilterDefinitions *C.char, inputDialect *C.CsvDialect, outputDialect *C.CsvDialect) csv.Options {
+	return csv.Options{
+		SelectedColumns:      C.GoString(selectedColumns),
+		RowFilterDefinitions: C.GoString(rowFilterDefinitions),
+		InputDialect:         goDialect(inputDialect),
+		DetectDialect:        inputDialect == nil,
+		OutputDialect:        goDialect(outputDialect),
+	}
+}
+
+// lenientOptions builds the options of the Lenient functions, whose
+// rejected records go to the file and the callback of rejectOptions, which
+// may be NULL. The returned function closes the reject file.
+func lenientOptions(selectedColumns *C.char, rowFilterDefinitions *C.char, rejectOptions *C.CsvRejectOptions) (csv.Options, func() error, error) {
+	opts := csv.Options{
+		SelectedColumns:      C.GoString(selectedColumns),
+		RowFilterDefinitions: C.GoString(rowFilterDefinitions),
+		Lenient:              true,
+	}
+	noClose := func() error { return nil }
+	if rejectOptions == nil {
+		return opts, noClose, nil
+	}
+	opts.MaxRejects = int(rejectOptions.maxRejects)
+	if callback, userData := rejectOptions.onReject, rejectOptions.userData; callback != nil {
+		opts.OnReject = func(line int, reason string, fields []string) error {
+			callRejectCallback(callback, userData, line, reason, fields)
+			return nil
+		}
+	}
+	if rejectOptions.rejectsPath == nil {
+		return opts, noClose, nil
+	}
+	file, err := os.Create(C.GoString(rejectOptions.rejectsPath))
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.Rejects = file
+	return opts, file.Close, nil
+}
+
+// callRejectCallback passes a rejected row to the caller's callback as C
+// strings that are released when it returns.
+func callRejectCallback(callback C.CsvRejectCallback, userData unsafe.Pointer, line int, reason string, fields []string) {
+	cReason := C.CString(reason)
+	defer C.free(unsafe.Pointer(cReason))
+	cFields := (**C.char)(C.malloc(C.size_t(len(fields)+1) * C.size_t(unsafe.Sizeof((*C.char)(nil)))))
+	defer C.free(unsafe.Pointer(cFields))
+	fieldSlice := unsafe.Slice(cFields, len(fields)+1)
+	for i, field := range fields {
+		fieldSlice[i] = C.CString(field)
+		defer C.free(unsafe.Pointer(fieldSlice[i]))
+	}
+	fieldSlice[len(fields)] = nil
+	C.callRejectCallback(callback, C.int(line), cReason, cFields, C.int(len(fields)), userData)
+}
+
+// goQuery returns the Query behind a handle from csvPrepareQuery.
+func goQuery(query *C.CsvQuery) (*csv.Query, error) {
+	if query == nil {
+		return nil, errors.New("query must not be NULL")
+	}
+	return cgo.Handle(*(*C.uintptr_t)(unsafe.Pointer(query))).Value().(*csv.Query), nil
+}
+
+// goDialect converts a CsvDialect, in which zero fields take their RFC 4180
+// defaults like in csv.Dialect.
+func goDialect(d *C.CsvDialect) csv.Dialect {
+	if d == nil {
+		return csv.Dialect{}
+	}
+	dialect := csv.Dialect{Delimiter: rune(d.delimiter), Quote: rune(d.quote), Escape: rune(d.escape)}
+	if d.lineTerminator != nil {
+		dialect.LineTerminator = C.GoString(d.lineTerminator)
+	}
+	return dialect
+}
+
+// exportSniffResult fills result with malloc'd copies of sniffed, to be
+// released with freeCsvSniffResult. On error result is zeroed.
+func exportSniffResult(sniffed csv.SniffResult, err error, result *C.CsvSniffResult) C.int {
+	if result == nil {
+		return reportError(err)
+	}
+	*result = C.CsvSniffResult{}
+	if err != nil {
+		return reportError(err)
+	}
+	d := sniffed.Dialect
+	result.dialect = C.CsvDialect{delimiter: C.int(d.Delimiter), quote: C.int(d.Quote), escape: C.int(d.Escape)}
+	if d.LineTerminator != "" {
+		result.dialect.lineTerminator = C.CString(d.LineTerminator)
+	}
+	result.encoding = C.CString(sniffed.Encoding)
+	if sniffed.HasHeader {
+		result.hasHeader = 1
+	}
+	result.numColumns = C.int(len(sniffed.ColumnTypes))
+	if len(sniffed.ColumnTypes) > 0 {
+		result.columnTypes = (*C.CsvColumnType)(C.malloc(C.size_t(len(sniffed.ColumnTypes)) * C.size_t(unsafe.Sizeof(C.CsvColumnType(0)))))
+		types := unsafe.Slice(result.columnTypes, len(sniffed.ColumnTypes))
+		for i, t := range sniffed.ColumnTypes {
+			types[i] = C.CsvColumnType(t)
+		}
 	}
+	return reportError(nil)
 }
@@ -0,0 +1,8 @@
+#define LIBCSV_TYPES_ONLY
+#include "libcsv.h"
+
+// Go cannot call C function pointers, so rejected rows are handed to the
+// caller's callback through this function.
+void callRejectCallback(CsvRejectCallback callback, int line, const char* reason, const char** fields, int numFields, void* userData) {
+    callback(line, reason, fields, numFields, userData);
+}
@@ -0,0 +1,115 @@
+package main
+
+/*
+void setLastError(char* message, char* source, int line, int column, long long offset, int field);
+char* getLastErrorMessage(void);
+char* getLastErrorSource(void);
+int getLastErrorLine(void);
+int getLastErrorColumn(void);
+long long getLastErrorOffset(void);
+int getLastErrorField(void);
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"milenio.capital/code-challenge/pkg/csv"
+)
+
+// Status codes returned by every exported function, mirrored by the
+// CsvStatus enum in libcsv.h.
+const (
+	statusOK = iota
+	statusUnknownColumn
+	statusBadFilter
+	statusIO
+	statusParse
+	statusUnknown
+	statusInternal
+	statusBadSelection
+	statusTooManyRejects
+	statusHeaderMismatch
+)
+
+//export csvLastErrorMessage
+func csvLastErrorMessage() *C.char {
+	return C.getLastErrorMessage()
+}
+
+//export csvLastErrorSource
+func csvLastErrorSource() *C.char {
+	return C.getLastErrorSource()
+}
+
+//export csvLastErrorLine
+func csvLastErrorLine() C.int {
+	return C.getLastErrorLine()
+}
+
+//export csvLastErrorColumn
+func csvLastErrorColumn() C.int {
+	return C.getLastErrorColumn()
+}
+
+//export csvLastErrorOffset
+func csvLastErrorOffset() C.longlong {
+	return C.getLastErrorOffset()
+}
+
+//export csvLastErrorField
+func csvLastErrorField() C.int {
+	return C.getLastErrorField()
+}
+
+// reportError records err as the calling thread's last error and returns
+// the matching status code. A nil err clears the last error.
+func reportError(err error) C.int {
+	if err == nil {
+		C.setLastError(nil, nil, 0, 0, 0, 0)
+		return statusOK
+	}
+
+	status := statusUnknown
+	var pos csv.Position
+	var unknownColumnErr *csv.UnknownColumnError
+	var filterErr *csv.FilterSyntaxError
+	var selectionErr *csv.SelectionSyntaxError
+	var parseErr *csv.ParseError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &unknownColumnErr):
+		status, pos = statusUnknownColumn, unknownColumnErr.Position
+	case errors.As(err, &filterErr):
+		status, pos = statusBadFilter, filterErr.Position
+	case errors.As(err, &selectionErr):
+		status, pos = statusBadSelection, selectionErr.Position
+	case errors.Is(err, csv.ErrTooManyRejects):
+		status = statusTooManyRejects
+	case errors.As(err, &parseErr) && errors.Is(err, csv.ErrHeaderMismatch):
+		status, pos = statusHeaderMismatch, parseErr.Position
+	case errors.As(err, &parseErr):
+		status, pos = statusParse, parseErr.Position
+	case errors.As(err, &pathErr):
+		status, pos.Source = statusIO, pathErr.Path
+	}
+	var source *C.char
+	if pos.Source != "" {
+		source = C.CString(pos.Source)
+	}
+	C.setLastError(C.CString(err.Error()), source, C.int(pos.Line), C.int(pos.Column), C.longlong(pos.Offset), C.int(pos.Field))
+	return C.int(status)
+}
+
+// recoverPanic must be deferred by every exported function that can
+// panic. A Go panic unwinding into C would abort the host process, so it
+// is turned into statusInternal instead, or only recorded as the last
+// error if status is nil because the function returns no status.
+func recoverPanic(status *C.int) {
+	if r := recover(); r != nil {
+		C.setLastError(C.CString(fmt.Sprintf("internal error: %v", r)), nil, 0, 0, 0, 0)
+		if status != nil {
+			*status = statusInternal
+		}
+	}
+}
@@ -1,6 +1,10 @@
 #include <stdio.h>
 #include "libcsv.h"
 
+static void printReject(int line, const char* reason, const char** fields, int numFields, void* userData) {
+    printf("rejected line %d: %s: %s\n", line, reason, fields[0]);
+}
+
 int main() {
     char* csvData = "col1,col2,col3,col4,col5,col6,col7\nl1c1,l1c2,l1c3,l1c4,l1c5,l1c6,l1c7\nl1c1,l1c2,l1c3,l1c4,l1c5,l1c6,l1c7\nl2c1,l2c2,l2c3,l2c4,l2c5,l2c6,l2c7\nl3c1,l3c2,l3c3,l3c4,l3c5,l3c6,l3c7\n";
     char* selectedColumns = {"col1,col3,col4,col7"};
@@ -12,6 +16,76 @@ int main() {
 
     printf("processCsvFile output:\n");
     processCsvFile("data.csv", selectedColumns, rowFilterDefinitions);
+    printf("\n");
+
+    char* output;
+    size_t outputLength;
+    printf("processCsvToBuffer output:\n");
+    if (processCsvToBuffer(csvData, selectedColumns, rowFilterDefinitions, &output, &outputLength) == 0) {
+        printf("%s", output);
+        freeCsvBuffer(output);
+    }
+    printf("\n");
+
+    printf("processCsvToBufferWithDialect output:\n");
+    CsvDialect tsv = {'\t', CSV_NO_QUOTE, '\\', NULL};
+    if (processCsvToBufferWithDialect("col1;col2\n\"a;b\";c\n", "", "", NULL, &tsv, &output, &outputLength) == CSV_OK) {
+        printf("%s", output);
+        freeCsvBuffer(output);
+    }
+    printf("\n");
+
+    printf("processCsvToBufferLenient output:\n");
+    CsvRejectOptions rejectOptions = {NULL, printReject, NULL, 10};
+    if (processCsvToBufferLenient("col1,col2\na,b\nc\"d,e\nf,g\n", "", "", &rejectOptions, &output, &outputLength) == CSV_OK) {
+        printf("%s", output);
+        freeCsvBuffer(output);
+    }
+    printf("\n");
+
+    printf("csvQueryRunToBuffer output:\n");
+    char* columns[] = {"col1", "col2", "col3", "col4", "col5", "col6", "col7"};
+    CsvQuery* query;
+    if (csvPrepareQuery(columns, 7, "col1,col7", "col1>l1c1", &query) == CSV_OK) {
+        if (csvQueryRunToBuffer(query, csvData, &output, &outputLength) == CSV_OK) {
+            printf("%s", output);
+            freeCsvBuffer(output);
+        }
+        if (csvQueryRunFileToBuffer(query, "data.csv", &output, &outputLength) == CSV_OK) {
+            printf("%s", output);
+            freeCsvBuffer(output);
+        }
+        if (csvQueryRunToBuffer(query, "col1,col2\na,b\n", &output, &outputLength) == CSV_ERR_HEADER_MISMATCH) {
+            printf("%s (field %d)\n", csvLastErrorMessage(), csvLastErrorField());
+        }
+        freeCsvQuery(query);
+    }
+    printf("\n");
+
+    printf("csvSniff result:\n");
+    CsvSniffResult sniffed;
+    if (csvSniff("id;amount\r\n1;2,50\r\n", &sniffed) == CSV_OK) {
+        printf("delimiter '%c', %s, header %d, %d columns\n", sniffed.dialect.delimiter, sniffed.encoding, sniffed.hasHeader, sniffed.numColumns);
+        freeCsvSniffResult(&sniffed);
+    }
+    printf("\n");
+
+    printf("processCsvToBuffer error:\n");
+    if (processCsvToBuffer("col1\n\"l1c1\n", "", "", &output, &outputLength) != CSV_OK) {
+        printf("%s (line %d, column %d, offset %lld, field %d)\n", csvLastErrorMessage(), csvLastErrorLine(), csvLastErrorColumn(), csvLastErrorOffset(), csvLastErrorField());
+    }
+    printf("\n");
+
+    printf("processCsvToBuffer selection error:\n");
+    if (processCsvToBuffer(csvData, "col1,col9", "", &output, &outputLength) != CSV_OK) {
+        printf("%s (source %s, field %d)\n", csvLastErrorMessage(), csvLastErrorSource(), csvLastErrorField());
+    }
+    printf("\n");
+
+    printf("processCsvToBuffer filter error:\n");
+    if (processCsvToBuffer(csvData, "", "col1>l1c1\ncol3 =>l1c3", &output, &outputLength) == CSV_ERR_BAD_FILTER) {
+        printf("%s (line %d, column %d)\n", csvLastErrorMessage(), csvLastErrorLine(), csvLastErrorColumn());
+    }
 
     return 0;
 }
\ No newline at end of file
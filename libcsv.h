@@ -1,21 +1,342 @@
+#ifndef LIBCSV_H
+#define LIBCSV_H
+
+#include <stddef.h>
+
+/**
+ * Status codes returned by the processing functions. When a function fails,
+ * csvLastErrorMessage describes the failure and the other csvLastError
+ * functions locate it.
+ */
+typedef enum {
+    CSV_OK = 0,               /* success */
+    CSV_ERR_UNKNOWN_COLUMN,   /* a selected or filtered column is not in the header */
+    CSV_ERR_BAD_FILTER,       /* a row filter definition is malformed */
+    CSV_ERR_IO,               /* the CSV file could not be opened or read */
+    CSV_ERR_PARSE,            /* the CSV data is malformed */
+    CSV_ERR_UNKNOWN,          /* any other failure */
+    CSV_ERR_INTERNAL,         /* a bug in the library; the call was aborted safely */
+    CSV_ERR_BAD_SELECTION,    /* a computed column of the selection is malformed */
+    CSV_ERR_TOO_MANY_REJECTS, /* more records were rejected than maxRejects allows */
+    CSV_ERR_HEADER_MISMATCH   /* the header of the input differs from that of the query */
+} CsvStatus;
+
+/** Value of CsvDialect.quote that disables quoting. */
+#define CSV_NO_QUOTE (-1)
+
+/**
+ * Describes how the fields and records of a CSV document are delimited and
+ * quoted. Zero fields take the RFC 4180 defaults, so a zeroed CsvDialect is
+ * comma-separated with '"' quotes escaped by doubling them. An output
+ * dialect with neither quote nor escape fails with CSV_ERR_UNKNOWN on a
+ * field containing the delimiter or a line break.
+ */
+typedef struct {
+    int delimiter;              /* field separator; 0 for ',' */
+    int quote;                  /* quote character; 0 for '"', CSV_NO_QUOTE for none */
+    int escape;                 /* escape character; 0 to double quotes instead */
+    const char* lineTerminator; /* written after each record; NULL for "\n" */
+} CsvDialect;
+
+/** How the values of a column compare, as inferred by csvSniff. */
+typedef enum {
+    CSV_TYPE_AUTO = 0,        /* unknown, all sampled values are empty */
+    CSV_TYPE_STRING,
+    CSV_TYPE_INTEGER,
+    CSV_TYPE_DECIMAL,
+    CSV_TYPE_TIMESTAMP
+} CsvColumnType;
+
+/**
+ * The shape of a CSV document as guessed by csvSniff. Release it with
+ * freeCsvSniffResult.
+ */
+typedef struct {
+    CsvDialect dialect;         /* delimiter and quote are always set */
+    char* encoding;             /* "UTF-8", "UTF-8 with BOM", "UTF-16LE", "UTF-16BE" or "windows-1252" */
+    int hasHeader;              /* non-zero if the first record looks like a header */
+    int numColumns;             /* number of entries in columnTypes */
+    CsvColumnType* columnTypes; /* type inferred for each column */
+} CsvSniffResult;
+
+/**
+ * Called with each record rejected by the Lenient functions.
+ *
+ * @param line The 1-based line on which the record starts.
+ * @param reason Why the record was rejected.
+ * @param fields The fields of the record, or its text if it could not be
+ *               parsed, followed by NULL. They are only valid during the call.
+ * @param numFields The number of entries in fields, excluding the NULL.
+ * @param userData CsvRejectOptions.userData.
+ */
+typedef void (*CsvRejectCallback)(int line, const char* reason, const char** fields, int numFields, void* userData);
+
+/** Where the Lenient functions send the records they reject. */
+typedef struct {
+    const char* rejectsPath;    /* file written with "line,reason,fields..." records, or NULL */
+    CsvRejectCallback onReject; /* called with each rejected record, or NULL */
+    void* userData;             /* passed to onReject */
+    int maxRejects;             /* fail with CSV_ERR_TOO_MANY_REJECTS past this many; 0 for no limit */
+} CsvRejectOptions;
+
+/**
+ * A selection and set of row filters compiled once by csvPrepareQuery and
+ * run against any number of inputs with the same header. The handle is
+ * opaque and may be used by several threads at once.
+ */
+typedef struct CsvQuery CsvQuery;
+
+/*
+ * The library itself includes this header with LIBCSV_TYPES_ONLY defined,
+ * so that the types above have a single definition, and declares the
+ * functions below through cgo.
+ */
+#ifndef LIBCSV_TYPES_ONLY
+
 /**
  * Process the CSV data by applying filters and selecting columns.
  *
  * @param csv The CSV data to be processed.
- * @param selectedColumns The columns to be selected from the CSV data.
+ * @param selectedColumns The columns to be selected from the CSV data, as header
+ *                        names or expressions such as "price*qty AS total".
  * @param rowFilterDefinitions The filters to be applied to the CSV data.
  *
- * @return void
+ * @return A CsvStatus code.
  */
-void processCsv(const char[], const char[], const char[]);
+int processCsv(const char[], const char[], const char[]);
 
 /**
  * Process the CSV data by applying filters and selecting columns.
  *
  * @param csvFilePath The file path of the CSV to be processed.
- * @param selectedColumns The columns to be selected from the CSV data.
+ * @param selectedColumns The columns to be selected from the CSV data, as header
+ *                        names or expressions such as "price*qty AS total".
+ * @param rowFilterDefinitions The filters to be applied to the CSV data.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvFile(const char[], const char[], const char[]);
+
+/**
+ * Process the CSV data by applying filters and selecting columns, returning
+ * the result instead of printing it.
+ *
+ * @param csv The CSV data to be processed.
+ * @param selectedColumns The columns to be selected from the CSV data, as header
+ *                        names or expressions such as "price*qty AS total".
+ * @param rowFilterDefinitions The filters to be applied to the CSV data.
+ * @param output Receives a NUL-terminated buffer holding the processed CSV,
+ *               or NULL on failure. Release it with freeCsvBuffer.
+ * @param outputLength Receives the length of output, excluding the NUL.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvToBuffer(const char[], const char[], const char[], char**, size_t*);
+
+/**
+ * Process the CSV file by applying filters and selecting columns, returning
+ * the result instead of printing it.
+ *
+ * @param csvFilePath The file path of the CSV to be processed.
+ * @param selectedColumns The columns to be selected from the CSV data, as header
+ *                        names or expressions such as "price*qty AS total".
  * @param rowFilterDefinitions The filters to be applied to the CSV data.
+ * @param output Receives a NUL-terminated buffer holding the processed CSV,
+ *               or NULL on failure. Release it with freeCsvBuffer.
+ * @param outputLength Receives the length of output, excluding the NUL.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvFileToBuffer(const char[], const char[], const char[], char**, size_t*);
+
+/**
+ * Like processCsvToBuffer, with the dialects of the input and the output.
+ *
+ * @param inputDialect The dialect of csv, or NULL to detect it from the
+ *                     first records.
+ * @param outputDialect The dialect of output, or NULL for RFC 4180.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvToBufferWithDialect(const char[], const char[], const char[], const CsvDialect*, const CsvDialect*, char**, size_t*);
+
+/**
+ * Like processCsvFileToBuffer, with the dialects of the input and the
+ * output.
+ *
+ * @param inputDialect The dialect of the file, or NULL to detect it from
+ *                     the first records.
+ * @param outputDialect The dialect of output, or NULL for RFC 4180.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvFileToBufferWithDialect(const char[], const char[], const char[], const CsvDialect*, const CsvDialect*, char**, size_t*);
+
+/**
+ * Like processCsvFile, detecting the dialect and encoding of the file from
+ * its first records.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvFileAuto(const char[], const char[], const char[]);
+
+/**
+ * Like processCsvToBuffer, but records that cannot be parsed are rejected
+ * and processing carries on with the next line.
+ *
+ * @param rejectOptions Where the rejected records go, or NULL to discard
+ *                      them without limit.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvToBufferLenient(const char[], const char[], const char[], const CsvRejectOptions*, char**, size_t*);
+
+/**
+ * Like processCsvFileToBuffer, but records that cannot be parsed are
+ * rejected and processing carries on with the next line.
+ *
+ * @param rejectOptions Where the rejected records go, or NULL to discard
+ *                      them without limit.
+ *
+ * @return A CsvStatus code.
+ */
+int processCsvFileToBufferLenient(const char[], const char[], const char[], const CsvRejectOptions*, char**, size_t*);
+
+/**
+ * Compile the selection and row filters against a header, resolving the
+ * column names once so that they need not be parsed again for every input.
+ *
+ * @param columns The column names of the header the inputs will have. They
+ *                are copied, not modified.
+ * @param numColumns The number of entries in columns.
+ * @param selectedColumns The columns to be selected, as for processCsv.
+ * @param rowFilterDefinitions The row filters, as for processCsv.
+ * @param query Receives the compiled query, or NULL on failure. Release it
+ *              with freeCsvQuery.
+ *
+ * @return A CsvStatus code.
+ */
+int csvPrepareQuery(char**, int, const char[], const char[], CsvQuery**);
+
+/**
+ * Run a compiled query over CSV data, like processCsvToBuffer. The first
+ * record of the data must equal the header the query was prepared with,
+ * or the call fails with CSV_ERR_HEADER_MISMATCH.
+ *
+ * @param query The query returned by csvPrepareQuery.
+ * @param csv The CSV data to be processed.
+ * @param output Receives the processed CSV as a NUL-terminated buffer, or
+ *               NULL on failure. Release it with freeCsvBuffer.
+ * @param outputLength Receives the length of output, excluding the NUL.
+ *
+ * @return A CsvStatus code.
+ */
+int csvQueryRunToBuffer(const CsvQuery*, const char[], char**, size_t*);
+
+/**
+ * Like csvQueryRunToBuffer, reading the CSV data from a file.
+ *
+ * @param query The query returned by csvPrepareQuery.
+ * @param csvFilePath The file path of the CSV to be processed.
+ *
+ * @return A CsvStatus code.
+ */
+int csvQueryRunFileToBuffer(const CsvQuery*, const char[], char**, size_t*);
+
+/**
+ * Release a query returned by csvPrepareQuery. Releasing a query twice is
+ * undefined, but a query whose handle is not valid is left alone and
+ * reported by csvLastErrorMessage rather than aborting the process.
+ *
+ * @param query The query to be released. NULL is ignored.
  *
  * @return void
  */
-void processCsvFile(const char[], const char[], const char[]);
+void freeCsvQuery(CsvQuery*);
+
+/**
+ * Guess the dialect, encoding, column types and header presence of CSV data
+ * from its first records.
+ *
+ * @param csv The CSV data to be inspected.
+ * @param result Receives the guess, or zeroes on failure.
+ *
+ * @return A CsvStatus code.
+ */
+int csvSniff(const char[], CsvSniffResult*);
+
+/**
+ * Like csvSniff, inspecting the first 64 KiB of a file.
+ *
+ * @param csvFilePath The file path of the CSV to be inspected.
+ * @param result Receives the guess, or zeroes on failure.
+ *
+ * @return A CsvStatus code.
+ */
+int csvSniffFile(const char[], CsvSniffResult*);
+
+/**
+ * Release the strings and arrays of a result filled by csvSniff or
+ * csvSniffFile, and zero it.
+ *
+ * @param result The result to be released. NULL is ignored.
+ *
+ * @return void
+ */
+void freeCsvSniffResult(CsvSniffResult*);
+
+/**
+ * Release a buffer returned by one of the ToBuffer functions.
+ *
+ * @param buffer The buffer to be released. NULL is ignored.
+ *
+ * @return void
+ */
+void freeCsvBuffer(char*);
+
+/**
+ * Describe the failure of the last processing call made by this thread.
+ * The state is kept per thread and is reset by every processing call.
+ *
+ * @return The error message, or NULL if the last call succeeded. The string
+ *         is owned by the library and valid until the next processing call
+ *         on the same thread.
+ */
+const char* csvLastErrorMessage(void);
+
+/**
+ * @return Where the last error of this thread occurred: the file path of the
+ *         input, or the argument holding a faulty definition, such as
+ *         "SelectedColumns" or "RowFilterDefinitions". NULL if the input is
+ *         a string or the error is not tied to a source. The string is owned
+ *         like the message.
+ */
+const char* csvLastErrorSource(void);
+
+/**
+ * @return The 1-based line of the source where the last error of this thread
+ *         occurred, or 0 if it is not tied to a position.
+ */
+int csvLastErrorLine(void);
+
+/**
+ * @return The 1-based column, in bytes, of the source where the last error of
+ *         this thread occurred, or 0 if it is not tied to a position.
+ */
+int csvLastErrorColumn(void);
+
+/**
+ * @return The number of bytes of the source before the last error of this
+ *         thread, or 0 if it is not tied to a position.
+ */
+long long csvLastErrorOffset(void);
+
+/**
+ * @return The 1-based field of the record, or entry of selectedColumns, at
+ *         fault in the last error of this thread, or 0 if unknown.
+ */
+int csvLastErrorField(void);
+
+#endif /* LIBCSV_TYPES_ONLY */
+
+#endif /* LIBCSV_H */
@@ -0,0 +1,65 @@
+package csv
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"strconv"
+	"strings"
+)
+
+// Collation controls how header names and string values are compared.
+type Collation int
+
+const (
+	// CollateBinary compares strings byte by byte.
+	CollateBinary Collation = iota
+	// CollateNFC compares the NFC normalisations of strings, so composed
+	// and decomposed accented characters are equal.
+	CollateNFC
+	// CollateNoCase is CollateNFC with Unicode case folding, so "Ação",
+	// "AÇÃO" and "ação" are equal.
+	CollateNoCase
+)
+
+func (c Collation) String() string {
+	switch c {
+	case CollateBinary:
+		return "BINARY"
+	case CollateNFC:
+		return "NFC"
+	case CollateNoCase:
+		return "NOCASE"
+	default:
+		return "Collation(" + strconv.Itoa(int(c)) + ")"
+	}
+}
+
+// parseCollation returns the Collation named s, in any case.
+func parseCollation(s string) (Collation, bool) {
+	for _, c := range []Collation{CollateBinary, CollateNFC, CollateNoCase} {
+		if strings.EqualFold(s, c.String()) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// key returns the form of s that is compared byte by byte under c.
+func (c Collation) key(s string) string {
+	switch c {
+	case CollateNFC:
+		return norm.NFC.String(s)
+	case CollateNoCase:
+		return norm.NFC.String(cases.Fold().String(s))
+	default:
+		return s
+	}
+}
+
+func (c Collation) compare(a, b string) int {
+	return strings.Compare(c.key(a), c.key(b))
+}
+
+func (c Collation) equal(a, b string) bool {
+	return c.key(a) == c.key(b)
+}
@@ -0,0 +1,54 @@
+package csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCollationEqual(t *testing.T) {
+	composed := "a\u00e7\u00e3o"
+	decomposed := "ac\u0327a\u0303o"
+	tests := []struct {
+		name      string
+		a         string
+		b         string
+		collation Collation
+		expected  bool
+	}{
+		{name: "Binary identical", a: "Status", b: "Status", collation: CollateBinary, expected: true},
+		{name: "Binary case differs", a: "Status", b: "status", collation: CollateBinary, expected: false},
+		{name: "Binary composition differs", a: composed, b: decomposed, collation: CollateBinary, expected: false},
+		{name: "NFC composition differs", a: composed, b: decomposed, collation: CollateNFC, expected: true},
+		{name: "NFC case differs", a: "Status", b: "status", collation: CollateNFC, expected: false},
+		{name: "NoCase case differs", a: "Status", b: "STATUS", collation: CollateNoCase, expected: true},
+		{name: "NoCase case and composition differ", a: "A\u00c7\u00c3O", b: decomposed, collation: CollateNoCase, expected: true},
+		{name: "NoCase different letters", a: "acao", b: composed, collation: CollateNoCase, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.collation.equal(tt.a, tt.b))
+		})
+	}
+}
+
+func TestParseCollation(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Collation
+		valid    bool
+	}{
+		{name: "binary", expected: CollateBinary, valid: true},
+		{name: "NFC", expected: CollateNFC, valid: true},
+		{name: "NoCase", expected: CollateNoCase, valid: true},
+		{name: "latin1", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, valid := parseCollation(tt.name)
+			assert.Equal(t, tt.valid, valid)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,546 @@
+package csv
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// divisionScale is the number of fraction digits kept when a quotient has
+// no exact decimal representation.
+const divisionScale = 16
+
+// maxRoundDigits bounds the fraction digits of round, whose result would
+// otherwise be as long as the digits argument asks for.
+const maxRoundDigits = 100
+
+// valueExpr is an expression of the selection list, evaluated against each
+// row. eval reports false if the result is NULL.
+type valueExpr interface {
+	eval(row []string, h *CsvHeader) (string, bool)
+}
+
+// selectedExpr is a computed output column.
+type selectedExpr struct {
+	name string
+	expr valueExpr
+}
+
+// columnRef evaluates to the field at index. A missing field or one equal
+// to a null token is NULL, but the field is still returned as is so that
+// an aliased column keeps its original value.
+type columnRef struct {
+	index int
+}
+
+func (e columnRef) eval(row []string, h *CsvHeader) (string, bool) {
+	if e.index >= len(row) {
+		return "", false
+	}
+	return row[e.index], !h.isNull(row[e.index])
+}
+
+type literal struct {
+	value string
+}
+
+func (e literal) eval([]string, *CsvHeader) (string, bool) {
+	return e.value, true
+}
+
+// arithmeticExpr applies op, one of "+-*/%", to two numbers. A NULL or
+// non-numeric operand and division by zero yield NULL.
+type arithmeticExpr struct {
+	op          byte
+	left, right valueExpr
+}
+
+func (e arithmeticExpr) eval(row []string, h *CsvHeader) (string, bool) {
+	x, ok := evalNumber(e.left, row, h)
+	if !ok {
+		return "", false
+	}
+	y, ok := evalNumber(e.right, row, h)
+	if !ok {
+		return "", false
+	}
+	r := new(big.Rat)
+	switch e.op {
+	case '+':
+		r.Add(x, y)
+	case '-':
+		r.Sub(x, y)
+	case '*':
+		r.Mul(x, y)
+	case '/', '%':
+		if y.Sign() == 0 {
+			return "", false
+		}
+		r.Quo(x, y)
+		if e.op == '%' {
+			q := new(big.Int).Quo(r.Num(), r.Denom())
+			r.Sub(x, new(big.Rat).Mul(y, new(big.Rat).SetInt(q)))
+		}
+	}
+	return formatNumber(r), true
+}
+
+type negateExpr struct {
+	expr valueExpr
+}
+
+func (e negateExpr) eval(row []string, h *CsvHeader) (string, bool) {
+	x, ok := evalNumber(e.expr, row, h)
+	if !ok {
+		return "", false
+	}
+	return formatNumber(x.Neg(x)), true
+}
+
+// concatExpr joins two strings. Unlike the other operators it treats NULL
+// as an empty string, so that "first || ' ' || last" never loses a name.
+type concatExpr struct {
+	left, right valueExpr
+}
+
+func (e concatExpr) eval(row []string, h *CsvHeader) (string, bool) {
+	x, ok := e.left.eval(row, h)
+	if !ok {
+		x = ""
+	}
+	y, ok := e.right.eval(row, h)
+	if !ok {
+		y = ""
+	}
+	return x + y, true
+}
+
+type callExpr struct {
+	fn   *function
+	args []valueExpr
+}
+
+func (e callExpr) eval(row []string, h *CsvHeader) (string, bool) {
+	args := make([]string, len(e.args))
+	nulls := make([]bool, len(e.args))
+	for i, arg := range e.args {
+		var ok bool
+		args[i], ok = arg.eval(row, h)
+		nulls[i] = !ok
+	}
+	return e.fn.call(args, nulls)
+}
+
+// function is a built-in function of the selection list. Unless stated
+// otherwise a NULL argument makes the result NULL.
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []string, nulls []bool) (string, bool)
+}
+
+var functions = map[string]*function{
+	"upper": {1, 1, strict(func(args []string) (string, bool) {
+		return strings.ToUpper(args[0]), true
+	})},
+	"lower": {1, 1, strict(func(args []string) (string, bool) {
+		return strings.ToLower(args[0]), true
+	})},
+	"trim": {1, 1, strict(func(args []string) (string, bool) {
+		return strings.TrimSpace(args[0]), true
+	})},
+	// substr(s, start[, length]) counts characters from 1.
+	"substr": {2, 3, strict(func(args []string) (string, bool) {
+		start, ok := parseInteger(args[1])
+		if !ok {
+			return "", false
+		}
+		runes := []rune(args[0])
+		start = min(max(start, 1), int64(len(runes))+1) - 1
+		end := int64(len(runes))
+		if len(args) == 3 {
+			length, ok := parseInteger(args[2])
+			if !ok || length < 0 {
+				return "", false
+			}
+			if length < end-start {
+				end = start + length
+			}
+		}
+		return string(runes[start:end]), true
+	})},
+	// round(x[, digits]) rounds halves away from zero. More than
+	// maxRoundDigits digits yield NULL.
+	"round": {1, 2, strict(func(args []string) (string, bool) {
+		x, ok := parseNumber(args[0])
+		if !ok {
+			return "", false
+		}
+		var digits int64
+		if len(args) == 2 {
+			if digits, ok = parseInteger(args[1]); !ok || digits < 0 || digits > maxRoundDigits {
+				return "", false
+			}
+		}
+		s := x.FloatString(int(digits))
+		if strings.Trim(s, "-0.") == "" {
+			s = strings.TrimPrefix(s, "-")
+		}
+		return s, true
+	})},
+	// coalesce returns its first argument that is not NULL.
+	"coalesce": {1, -1, func(args []string, nulls []bool) (string, bool) {
+		for i, arg := range args {
+			if !nulls[i] {
+				return arg, true
+			}
+		}
+		return "", false
+	}},
+}
+
+// strict wraps fn so that it returns NULL if any argument is NULL.
+func strict(fn func(args []string) (string, bool)) func([]string, []bool) (string, bool) {
+	return func(args []string, nulls []bool) (string, bool) {
+		for _, null := range nulls {
+			if null {
+				return "", false
+			}
+		}
+		return fn(args)
+	}
+}
+
+func evalNumber(e valueExpr, row []string, h *CsvHeader) (*big.Rat, bool) {
+	s, ok := e.eval(row, h)
+	if !ok {
+		return nil, false
+	}
+	return parseNumber(s)
+}
+
+func parseNumber(s string) (*big.Rat, bool) {
+	d, ok := parseDecimal(s)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(d.String())
+}
+
+// formatNumber formats r exactly if it has a finite decimal expansion and
+// rounded to divisionScale fraction digits otherwise, without trailing
+// zeros.
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	denom := new(big.Int).Set(r.Denom())
+	var twos, fives int
+	for denom.Bit(0) == 0 {
+		denom.Rsh(denom, 1)
+		twos++
+	}
+	five := big.NewInt(5)
+	for m := new(big.Int); ; fives++ {
+		q, _ := new(big.Int).QuoRem(denom, five, m)
+		if m.Sign() != 0 {
+			break
+		}
+		denom = q
+	}
+	scale := divisionScale
+	if denom.Cmp(big.NewInt(1)) == 0 {
+		scale = max(twos, fives)
+	}
+	s := strings.TrimRight(strings.TrimRight(r.FloatString(scale), "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// parseSelectedExpr parses a selection list entry of the form
+// "expr [AS alias]".
+//
+//	expr   = concat
+//	concat = sum { "||" sum }
+//	sum    = term { ( "+" | "-" ) term }
+//	term   = unary { ( "*" | "/" | "%" ) unary }
+//	unary  = "-" unary | "(" expr ")" | number | string | name "(" [ expr { "," expr } ] ")" | column
+//	column = name | "`" any "`"
+//
+// Strings are enclosed in single quotes. A quote, or a backquote within a
+// column, is escaped by doubling it.
+func parseSelectedExpr(definition string, h *CsvHeader) (valueExpr, string, error) {
+	p := &exprParser{definition: definition, header: h}
+	expr, err := p.parseConcat()
+	if err != nil {
+		return nil, "", err
+	}
+	name := strings.TrimSpace(definition)
+	p.skipSpaces()
+	if p.acceptKeyword("AS") {
+		if name, err = p.parseAlias(); err != nil {
+			return nil, "", err
+		}
+	}
+	if p.pos < len(p.definition) {
+		return nil, "", p.errorf("unexpected '%s'", p.definition[p.pos:])
+	}
+	return expr, name, nil
+}
+
+// parseAlias parses the name following AS, bare or backquoted, and the
+// spaces after it.
+func (p *exprParser) parseAlias() (string, error) {
+	p.skipSpaces()
+	var name string
+	var err error
+	if p.pos < len(p.definition) && p.definition[p.pos] == '`' {
+		name, err = p.parseQuoted()
+	} else {
+		name = p.parseName()
+	}
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", p.errorf("missing alias after AS")
+	}
+	p.skipSpaces()
+	return name, nil
+}
+
+type exprParser struct {
+	definition string
+	pos        int
+	header     *CsvHeader
+}
+
+func (p *exprParser) parseConcat() (valueExpr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOperator("||") {
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		left = concatExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseSum() (valueExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.acceptOperator("+"):
+			op = '+'
+		case p.acceptOperator("-"):
+			op = '-'
+		default:
+			return left, nil
+		}
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseTerm() (valueExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.acceptOperator("*"):
+			op = '*'
+		case p.acceptOperator("/"):
+			op = '/'
+		case p.acceptOperator("%"):
+			op = '%'
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (valueExpr, error) {
+	p.skipSpaces()
+	if p.pos == len(p.definition) {
+		return nil, p.errorf("missing operand")
+	}
+	operandPos := p.pos
+	switch c := p.definition[p.pos]; {
+	case c == '-':
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negateExpr{expr: expr}, nil
+	case c == '(':
+		p.pos++
+		expr, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOperator(")") {
+			return nil, p.errorf("missing ')'")
+		}
+		return expr, nil
+	case c == '\'':
+		value, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return literal{value: value}, nil
+	case c == '`':
+		col, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return p.column(col, operandPos)
+	case c == '.' || c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.definition) && (p.definition[p.pos] == '.' || p.definition[p.pos] >= '0' && p.definition[p.pos] <= '9') {
+			p.pos++
+		}
+		number := p.definition[start:p.pos]
+		if _, ok := parseDecimal(number); !ok {
+			return nil, p.errorf("invalid number '%s'", number)
+		}
+		return literal{value: number}, nil
+	}
+
+	name := p.parseName()
+	if name == "" {
+		return nil, p.errorf("unexpected '%s'", p.definition[p.pos:])
+	}
+	if !p.acceptOperator("(") {
+		return p.column(name, operandPos)
+	}
+	fn, ok := functions[strings.ToLower(name)]
+	if !ok {
+		return nil, p.errorf("unknown function '%s'", name)
+	}
+	var args []valueExpr
+	if !p.acceptOperator(")") {
+		for {
+			arg, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.acceptOperator(")") {
+				break
+			}
+			if !p.acceptOperator(",") {
+				return nil, p.errorf("missing ')'")
+			}
+		}
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, p.errorf("wrong number of arguments to %s", strings.ToLower(name))
+	}
+	if fn == functions["round"] && len(args) == 2 {
+		if digits, ok := args[1].(literal); ok {
+			if n, ok := parseInteger(digits.value); ok && n > maxRoundDigits {
+				return nil, p.errorf("round digits exceed %d", maxRoundDigits)
+			}
+		}
+	}
+	return callExpr{fn: fn, args: args}, nil
+}
+
+// column returns a reference to the column name, which starts at pos.
+func (p *exprParser) column(name string, pos int) (valueExpr, error) {
+	i := p.header.indexOf(name, p.header.collation)
+	if i == -1 {
+		return nil, &UnknownColumnError{Position: Position{Offset: int64(pos)}, Column: name}
+	}
+	return columnRef{index: i}, nil
+}
+
+// parseName parses a bare name made of letters, digits and underscores.
+func (p *exprParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.definition) {
+		r, size := utf8.DecodeRuneInString(p.definition[p.pos:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.definition[start:p.pos]
+}
+
+// parseQuoted parses a string or column enclosed in the quote at the
+// current position, in which the quote is escaped by doubling it.
+func (p *exprParser) parseQuoted() (string, error) {
+	quote := p.definition[p.pos]
+	var val strings.Builder
+	for i := p.pos + 1; i < len(p.definition); i++ {
+		if p.definition[i] != quote {
+			val.WriteByte(p.definition[i])
+			continue
+		}
+		if i+1 < len(p.definition) && p.definition[i+1] == quote {
+			val.WriteByte(quote)
+			i++
+			continue
+		}
+		p.pos = i + 1
+		return val.String(), nil
+	}
+	return "", p.errorf("unterminated quoted value")
+}
+
+func (p *exprParser) acceptOperator(op string) bool {
+	p.skipSpaces()
+	if !strings.HasPrefix(p.definition[p.pos:], op) {
+		return false
+	}
+	p.pos += len(op)
+	return true
+}
+
+// acceptKeyword consumes keyword, in any case, if it is a whole word at
+// the current position.
+func (p *exprParser) acceptKeyword(keyword string) bool {
+	end := p.pos + len(keyword)
+	if end > len(p.definition) || !strings.EqualFold(p.definition[p.pos:end], keyword) {
+		return false
+	}
+	if end < len(p.definition) && !isSpace(p.definition[end]) && p.definition[end] != '`' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.definition) && isSpace(p.definition[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return &SelectionSyntaxError{Position: Position{Offset: int64(p.pos)}, Definition: p.definition, Msg: fmt.Sprintf(format, args...)}
+}
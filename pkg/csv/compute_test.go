@@ -0,0 +1,121 @@
+package csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSelectedExpr(t *testing.T) {
+	header := CsvHeader{headers: []string{"name", "price", "qty", "unit price", "note"}, nullTokens: []string{"", "N/A"}}
+	row := []string{" Ação ", "25.50", "3", "1/3", "N/A"}
+	tests := []struct {
+		name       string
+		definition string
+		expected   string
+		alias      string
+		null       bool
+	}{
+		{name: "Multiplication", definition: "price*qty AS total", expected: "76.5", alias: "total"},
+		{name: "Precedence", definition: "price + qty * 2", expected: "31.5", alias: "price + qty * 2"},
+		{name: "Parentheses", definition: "(price + qty) * 2", expected: "57", alias: "(price + qty) * 2"},
+		{name: "Unary minus", definition: "-qty - -1", expected: "-2", alias: "-qty - -1"},
+		{name: "Inexact division", definition: "10 / qty", expected: "3.3333333333333333", alias: "10 / qty"},
+		{name: "Exact division", definition: "price / 4", expected: "6.375", alias: "price / 4"},
+		{name: "Modulo", definition: "qty % 2", expected: "1", alias: "qty % 2"},
+		{name: "Division by zero", definition: "price / 0", null: true, alias: "price / 0"},
+		{name: "Non-numeric operand", definition: "name + 1", null: true, alias: "name + 1"},
+		{name: "NULL operand", definition: "note * 2", null: true, alias: "note * 2"},
+		{name: "Concatenation", definition: "trim(name) || '-' || qty || note AS code", expected: "Ação-3", alias: "code"},
+		{name: "Escaped quote", definition: "'it''s'", expected: "it's", alias: "'it''s'"},
+		{name: "Upper", definition: "upper(trim(name))", expected: "AÇÃO", alias: "upper(trim(name))"},
+		{name: "Lower", definition: "LOWER(name) as n", expected: " ação ", alias: "n"},
+		{name: "Substr", definition: "substr(trim(name), 2, 2)", expected: "çã", alias: "substr(trim(name), 2, 2)"},
+		{name: "Substr with huge length", definition: "substr(name, 2, 9223372036854775807)", expected: "Ação ", alias: "substr(name, 2, 9223372036854775807)"},
+		{name: "Substr without length", definition: "substr(name, 3)", expected: "ção ", alias: "substr(name, 3)"},
+		{name: "Round", definition: "round(price / 7, 2)", expected: "3.64", alias: "round(price / 7, 2)"},
+		{name: "Round half away from zero", definition: "round(-2.5)", expected: "-3", alias: "round(-2.5)"},
+		{name: "Round to zero", definition: "round(-0.001, 2)", expected: "0.00", alias: "round(-0.001, 2)"},
+		{name: "Round to too many digits", definition: "round(price, qty * 1000)", null: true, alias: "round(price, qty * 1000)"},
+		{name: "Coalesce", definition: "coalesce(note, `unit price`)", expected: "1/3", alias: "coalesce(note, `unit price`)"},
+		{name: "Coalesce of NULLs", definition: "coalesce(note, price / 0)", null: true, alias: "coalesce(note, price / 0)"},
+		{name: "Backquoted alias", definition: "qty AS `item count`", expected: "3", alias: "item count"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, alias, err := parseSelectedExpr(tt.definition, &header)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.alias, alias)
+			value, ok := expr.eval(row, &header)
+			assert.Equal(t, tt.expected, value)
+			assert.Equal(t, !tt.null, ok)
+		})
+	}
+}
+
+func TestParseInvalidSelectedExpr(t *testing.T) {
+	header := CsvHeader{headers: []string{"name", "price"}}
+	tests := []struct {
+		name       string
+		definition string
+		expected   error
+	}{
+		{
+			name:       "Unknown column",
+			definition: "price * qty",
+			expected:   &UnknownColumnError{Position: Position{Offset: 8}, Column: "qty"},
+		},
+		{
+			name:       "Unknown function",
+			definition: "reverse(name)",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 8}, Definition: "reverse(name)", Msg: "unknown function 'reverse'"},
+		},
+		{
+			name:       "Wrong number of arguments",
+			definition: "upper(name, price)",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 18}, Definition: "upper(name, price)", Msg: "wrong number of arguments to upper"},
+		},
+		{
+			name:       "Missing operand",
+			definition: "price *",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 7}, Definition: "price *", Msg: "missing operand"},
+		},
+		{
+			name:       "Missing parenthesis",
+			definition: "(price + 1",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 10}, Definition: "(price + 1", Msg: "missing ')'"},
+		},
+		{
+			name:       "Unterminated string",
+			definition: "name || 'x",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 8}, Definition: "name || 'x", Msg: "unterminated quoted value"},
+		},
+		{
+			name:       "Missing alias",
+			definition: "price AS",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 8}, Definition: "price AS", Msg: "missing alias after AS"},
+		},
+		{
+			name:       "Trailing input",
+			definition: "price 2",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 6}, Definition: "price 2", Msg: "unexpected '2'"},
+		},
+		{
+			name:       "Too many round digits",
+			definition: "round(price, 999999999999)",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 26}, Definition: "round(price, 999999999999)", Msg: "round digits exceed 100"},
+		},
+		{
+			name:       "Invalid number",
+			definition: "price * 1.2.3",
+			expected:   &SelectionSyntaxError{Position: Position{Offset: 13}, Definition: "price * 1.2.3", Msg: "invalid number '1.2.3'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseSelectedExpr(tt.definition, &header)
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// NoQuote disables quoting when used as Dialect.Quote.
+const NoQuote rune = -1
+
+// sniffSize is the number of bytes inspected to detect a dialect.
+const sniffSize = 64 * 1024
+
+// sniffLines is the maximum number of records inspected to detect a
+// dialect.
+const sniffLines = 20
+
+// Dialect describes how the fields and records of a CSV document are
+// delimited and quoted. The zero value is RFC 4180: fields separated by
+// commas, quoted with '"' when needed, a quote within a quoted field
+// escaped by doubling it, and records terminated by "\n".
+type Dialect struct {
+	// Delimiter separates fields. Zero means ','.
+	Delimiter rune
+	// Quote encloses fields containing the delimiter, the quote or a line
+	// break. Zero means '"' and NoQuote disables quoting.
+	Quote rune
+	// Escape, if not zero, escapes the next character instead of doubling
+	// quotes, as in "say \"hi\"". Without quoting it escapes delimiters
+	// and line breaks within fields, and without either writing such a
+	// field fails with ErrUnencodable.
+	Escape rune
+	// LineTerminator ends each written record. Empty means "\n". Input
+	// records may end with "\n" or "\r\n" regardless.
+	LineTerminator string
+}
+
+// TSV is the tab-separated dialect, which neither quotes nor escapes
+// fields, so backslashes such as in "C:\temp" are read as they are and
+// writing a field with a tab or a line break fails with ErrUnencodable.
+var TSV = Dialect{Delimiter: '\t', Quote: NoQuote}
+
+func (d Dialect) delimiter() byte {
+	if d.Delimiter == 0 {
+		return ','
+	}
+	return byte(d.Delimiter)
+}
+
+// quote returns the quote character, or 0 if quoting is disabled.
+func (d Dialect) quote() byte {
+	switch d.Quote {
+	case 0:
+		return '"'
+	case NoQuote:
+		return 0
+	default:
+		return byte(d.Quote)
+	}
+}
+
+// escape returns the escape character, or 0 if quotes are doubled.
+func (d Dialect) escape() byte {
+	if d.Escape == 0 || d.Escape == d.Quote || d.Escape == '"' && d.Quote == 0 {
+		return 0
+	}
+	return byte(d.Escape)
+}
+
+func (d Dialect) lineTerminator() string {
+	if d.LineTerminator == "" {
+		return "\n"
+	}
+	return d.LineTerminator
+}
+
+// validate checks that the special characters are ASCII characters other
+// than line breaks and that the delimiter differs from the others.
+func (d Dialect) validate() error {
+	chars := []struct {
+		name string
+		r    rune
+	}{{"delimiter", d.Delimiter}, {"quote", d.Quote}, {"escape", d.Escape}}
+	for _, c := range chars {
+		if c.r == 0 || c.name == "quote" && c.r == NoQuote {
+			continue
+		}
+		if c.r < 0 || c.r >= utf8.RuneSelf || c.r == '\r' || c.r == '\n' {
+			return fmt.Errorf("invalid dialect: %q is not a valid %s", c.r, c.name)
+		}
+	}
+	if d.delimiter() == d.quote() || d.delimiter() == d.escape() {
+		return fmt.Errorf("invalid dialect: %q is both the delimiter and the quote or escape", d.delimiter())
+	}
+	switch d.LineTerminator {
+	case "", "\n", "\r\n", "\r":
+		return nil
+	default:
+		return fmt.Errorf("invalid dialect: %q is not a valid line terminator", d.LineTerminator)
+	}
+}
+
+// detectDialect guesses the dialect of the leading records in sample. If
+// complete is false the last line of sample may be truncated and is
+// ignored. The delimiter is the candidate that splits the most records
+// into the same number of fields, preferring more fields. A backslash is
+// the escape if it precedes quotes and quotes are never doubled otherwise.
+func detectDialect(sample []byte, complete bool) Dialect {
+	text := string(sample)
+	if !complete {
+		if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+			text = text[:i+1]
+		}
+	}
+
+	var best Dialect
+	bestConsistent, bestFields := 0, 1
+	for _, delimiter := range []rune{',', ';', '\t', '|'} {
+		for _, quote := range []rune{'"', '\''} {
+			counts := countFields(text, byte(delimiter), byte(quote))
+			fields, consistent := modalCount(counts)
+			if fields > 1 && (consistent > bestConsistent || consistent == bestConsistent && fields > bestFields) {
+				best = Dialect{Delimiter: delimiter, Quote: quote}
+				bestConsistent, bestFields = consistent, fields
+			}
+		}
+	}
+	if best.Delimiter == ',' {
+		best.Delimiter = 0
+	}
+	if best.Quote == '"' || !strings.ContainsRune(text, best.Quote) {
+		best.Quote = 0
+	}
+	if q := string(best.quote()); strings.Contains(text, `\`+q) && strings.Count(text, q+q) == strings.Count(text, `\`+q+q) {
+		best.Escape = '\\'
+	}
+	return best
+}
+
+// countFields returns the number of fields of each of the first sniffLines
+// non-empty records of text. A quote only opens a quoted field at its
+// start.
+func countFields(text string, delimiter, quote byte) []int {
+	var counts []int
+	fields, quoted, fieldStart, recordStart := 1, false, true, 0
+	for i := 0; i < len(text) && len(counts) < sniffLines; i++ {
+		c := text[i]
+		switch {
+		case quoted:
+			if c == quote {
+				if i+1 < len(text) && text[i+1] == quote {
+					i++
+				} else {
+					quoted = false
+				}
+			}
+		case c == quote && fieldStart:
+			quoted = true
+		case c == delimiter:
+			fields++
+			fieldStart = true
+			continue
+		case c == '\n':
+			if strings.TrimRight(text[recordStart:i], "\r") != "" {
+				counts = append(counts, fields)
+			}
+			fields, fieldStart, recordStart = 1, true, i+1
+			continue
+		}
+		fieldStart = false
+	}
+	if recordStart < len(text) && !quoted && len(counts) < sniffLines {
+		counts = append(counts, fields)
+	}
+	return counts
+}
+
+// modalCount returns the most frequent count and its frequency.
+func modalCount(counts []int) (int, int) {
+	frequency := make(map[int]int)
+	mode := 0
+	for _, n := range counts {
+		frequency[n]++
+		if frequency[n] > frequency[mode] || frequency[n] == frequency[mode] && n > mode {
+			mode = n
+		}
+	}
+	return mode, frequency[mode]
+}
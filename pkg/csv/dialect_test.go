@@ -0,0 +1,126 @@
+package csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDialectValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		expected error
+	}{
+		{
+			name:     "Default dialect",
+			dialect:  Dialect{},
+			expected: nil,
+		},
+		{
+			name:     "TSV",
+			dialect:  TSV,
+			expected: nil,
+		},
+		{
+			name:     "Escape equal to quote",
+			dialect:  Dialect{Quote: '\'', Escape: '\''},
+			expected: nil,
+		},
+		{
+			name:     "Non-ASCII delimiter",
+			dialect:  Dialect{Delimiter: '¦'},
+			expected: errors.New("invalid dialect: '¦' is not a valid delimiter"),
+		},
+		{
+			name:     "Line break as quote",
+			dialect:  Dialect{Quote: '\n'},
+			expected: errors.New("invalid dialect: '\\n' is not a valid quote"),
+		},
+		{
+			name:     "Delimiter equal to quote",
+			dialect:  Dialect{Delimiter: '"'},
+			expected: errors.New("invalid dialect: '\"' is both the delimiter and the quote or escape"),
+		},
+		{
+			name:     "Delimiter equal to escape",
+			dialect:  Dialect{Delimiter: '\\', Escape: '\\'},
+			expected: errors.New("invalid dialect: '\\\\' is both the delimiter and the quote or escape"),
+		},
+		{
+			name:     "Invalid line terminator",
+			dialect:  Dialect{LineTerminator: ";"},
+			expected: errors.New("invalid dialect: \";\" is not a valid line terminator"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dialect.validate())
+		})
+	}
+}
+
+func TestDetectDialect(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   string
+		complete bool
+		expected Dialect
+	}{
+		{
+			name:     "Comma",
+			sample:   "a,b,c\n1,2,3\n4,5,6\n",
+			complete: true,
+			expected: Dialect{},
+		},
+		{
+			name:     "Semicolon with decimal commas",
+			sample:   "nome;valor\nAção;1,50\nFeijão;2,75\n",
+			complete: true,
+			expected: Dialect{Delimiter: ';'},
+		},
+		{
+			name:     "Tab",
+			sample:   "a\tb\r\n1\t2\r\n\r\n3\t4",
+			complete: true,
+			expected: Dialect{Delimiter: '\t'},
+		},
+		{
+			name:     "Pipe with quoted pipes",
+			sample:   "a|b|c\n\"x|y\"|2|3\n",
+			complete: true,
+			expected: Dialect{Delimiter: '|'},
+		},
+		{
+			name:     "Single quotes",
+			sample:   "a,b\n'x,y',1\n'z,w',2\n",
+			complete: true,
+			expected: Dialect{Quote: '\''},
+		},
+		{
+			name:     "Backslash escapes",
+			sample:   "a,b\n\"say \\\"hi\\\"\",1\n",
+			complete: true,
+			expected: Dialect{Escape: '\\'},
+		},
+		{
+			name:     "Truncated last line is ignored",
+			sample:   "a;b\n1;2\n3,4,5,6",
+			complete: false,
+			expected: Dialect{Delimiter: ';'},
+		},
+		{
+			name:     "Single column",
+			sample:   "a\nb\n",
+			complete: true,
+			expected: Dialect{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectDialect([]byte(tt.sample), tt.complete))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Position locates an error in its source, which is either the CSV input
+// or the Options field holding a faulty definition. Every error type of
+// this package embeds one, so errors.As gives access to it. Fields that do
+// not apply are zero.
+type Position struct {
+	// Source is the name of the CSV input, such as its file path, or of
+	// the Options field, such as "RowFilterDefinitions". It is empty for
+	// unnamed input.
+	Source string
+	// Line and Column are 1-based. Column counts bytes.
+	Line   int
+	Column int
+	// Offset is the number of bytes of the source before the error. The
+	// input is counted after decoding when its dialect is detected.
+	Offset int64
+	// Field is the 1-based index of the field of a record, or of the entry
+	// of the selection list, at fault.
+	Field int
+}
+
+// String returns the position as "source:line:column", leaving out the
+// parts that are not known.
+func (p Position) String() string {
+	s := p.Source
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+	return s
+}
+
+// prefix returns the position followed by ": ", or nothing if it is not
+// known.
+func (p Position) prefix() string {
+	if s := p.String(); s != "" {
+		return s + ": "
+	}
+	return ""
+}
+
+func (p *Position) location() *Position {
+	return p
+}
+
+// locate places the position of err, which is relative to a definition,
+// in source: the definition is the field-th entry of source and starts on
+// line, at column and offset. Errors that are already placed, and errors
+// of other packages, are returned unchanged.
+func locate(err error, source string, line, column int, offset int64, field int) error {
+	var located interface{ location() *Position }
+	if !errors.As(err, &located) {
+		return err
+	}
+	if p := located.location(); p.Source == "" {
+		p.Source = source
+		p.Line = line
+		p.Column = column + int(p.Offset)
+		p.Offset += offset
+		if p.Field == 0 {
+			p.Field = field
+		}
+	}
+	return err
+}
+
+// UnknownColumnError reports a selected or filtered column that is not
+// part of the CSV header.
+type UnknownColumnError struct {
+	Position
+	Column string
+}
+
+func (e *UnknownColumnError) Error() string {
+	return fmt.Sprintf("%sHeader '%s' not found in CSV file/string", e.prefix(), e.Column)
+}
+
+// FilterSyntaxError reports a row filter definition that cannot be parsed.
+type FilterSyntaxError struct {
+	Position
+	Definition string
+	Msg        string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("%sInvalid filter '%s': %s", e.prefix(), e.Definition, e.Msg)
+}
+
+// SelectionSyntaxError reports a computed column of the selection list
+// that cannot be parsed.
+type SelectionSyntaxError struct {
+	Position
+	Definition string
+	Msg        string
+}
+
+func (e *SelectionSyntaxError) Error() string {
+	return fmt.Sprintf("%sInvalid column expression '%s': %s", e.prefix(), e.Definition, e.Msg)
+}
@@ -0,0 +1,39 @@
+package csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		name     string
+		position Position
+		expected string
+	}{
+		{name: "Unknown", position: Position{}, expected: ""},
+		{name: "Source only", position: Position{Source: "ColumnTypes"}, expected: "ColumnTypes"},
+		{name: "Line without source", position: Position{Line: 3}, expected: "3"},
+		{name: "Full", position: Position{Source: "data.csv", Line: 3, Column: 7, Offset: 40, Field: 2}, expected: "data.csv:3:7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.position.String())
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	err := locate(&FilterSyntaxError{Position: Position{Offset: 4}, Definition: "a = ", Msg: "missing value"}, "RowFilterDefinitions", 2, 1, 10, 0)
+	assert.Equal(t, &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 2, Column: 5, Offset: 14}, Definition: "a = ", Msg: "missing value"}, err)
+	assert.EqualError(t, err, "RowFilterDefinitions:2:5: Invalid filter 'a = ': missing value")
+
+	located := &UnknownColumnError{Position: Position{Source: "ColumnTypes"}, Column: "a"}
+	assert.Equal(t, located, locate(located, "SelectedColumns", 1, 1, 0, 1))
+	assert.Equal(t, Position{Source: "ColumnTypes"}, located.Position)
+
+	other := errors.New("other")
+	assert.Equal(t, other, locate(other, "SelectedColumns", 1, 1, 0, 1))
+}
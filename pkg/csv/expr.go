@@ -0,0 +1,123 @@
+package csv
+
+// FilterExpr is a compiled row filter. It is either a single Filter or a
+// combination of expressions built with AndExpr, OrExpr and NotExpr.
+type FilterExpr interface {
+	match(row []string, csvHeader CsvHeader) bool
+}
+
+// AndExpr matches a row if all of its expressions match. An empty AndExpr
+// matches every row.
+type AndExpr []FilterExpr
+
+// OrExpr matches a row if any of its expressions matches.
+type OrExpr []FilterExpr
+
+// NotExpr matches a row if Expr does not.
+type NotExpr struct {
+	Expr FilterExpr
+}
+
+func (e AndExpr) match(row []string, csvHeader CsvHeader) bool {
+	for _, expr := range e {
+		if !expr.match(row, csvHeader) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e OrExpr) match(row []string, csvHeader CsvHeader) bool {
+	for _, expr := range e {
+		if expr.match(row, csvHeader) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e NotExpr) match(row []string, csvHeader CsvHeader) bool {
+	return !e.Expr.match(row, csvHeader)
+}
+
+func (f Filter) match(row []string, csvHeader CsvHeader) bool {
+	return f.bind(csvHeader).match(row, csvHeader)
+}
+
+// boundFilter is a Filter whose columns are resolved to their indices in
+// a header, so that matching a row does not search the header. An index
+// is -1 if the column is not part of the header.
+type boundFilter struct {
+	Filter
+	index      int
+	valueIndex int
+	upperIndex int
+}
+
+// bind resolves the columns of f against csvHeader.
+func (f Filter) bind(csvHeader CsvHeader) boundFilter {
+	return boundFilter{
+		Filter:     f,
+		index:      headerIndex(f.column, csvHeader),
+		valueIndex: headerIndex(f.valueColumn, csvHeader),
+		upperIndex: headerIndex(f.upperColumn, csvHeader),
+	}
+}
+
+func (f boundFilter) match(row []string, _ CsvHeader) bool {
+	if f.index == -1 {
+		return false
+	}
+	if f.index >= len(row) {
+		return f.comparator == IsNull
+	}
+	if f.valueColumn != "" {
+		if f.valueIndex == -1 || f.valueIndex >= len(row) {
+			return false
+		}
+		f.value = row[f.valueIndex]
+		switch f.comparator {
+		case Contains, StartsWith, EndsWith:
+			f.key = f.collation.key(f.value)
+		}
+	}
+	if f.upperColumn != "" {
+		if f.upperIndex == -1 || f.upperIndex >= len(row) {
+			return false
+		}
+		f.upper = row[f.upperIndex]
+	}
+	return applyFilter(row[f.index], f.Filter)
+}
+
+// bindFilters returns expr with every Filter bound to csvHeader.
+func bindFilters(expr FilterExpr, csvHeader CsvHeader) FilterExpr {
+	switch e := expr.(type) {
+	case Filter:
+		return e.bind(csvHeader)
+	case AndExpr:
+		bound := make(AndExpr, len(e))
+		for i, expr := range e {
+			bound[i] = bindFilters(expr, csvHeader)
+		}
+		return bound
+	case OrExpr:
+		bound := make(OrExpr, len(e))
+		for i, expr := range e {
+			bound[i] = bindFilters(expr, csvHeader)
+		}
+		return bound
+	case NotExpr:
+		return NotExpr{Expr: bindFilters(e.Expr, csvHeader)}
+	}
+	return expr
+}
+
+// headerIndex returns the index of the header named exactly column, or -1
+// if there is none.
+func headerIndex(column string, csvHeader CsvHeader) int {
+	if column == "" {
+		return -1
+	}
+	return csvHeader.indexOf(column, CollateBinary)
+}
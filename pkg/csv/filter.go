@@ -2,81 +2,752 @@ package csv
 
 import (
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
 )
 
+// Comparator is the comparison operator of a Filter.
+type Comparator string
+
+const (
+	Equal          Comparator = "="
+	NotEqual       Comparator = "!="
+	Greater        Comparator = ">"
+	GreaterOrEqual Comparator = ">="
+	Less           Comparator = "<"
+	LessOrEqual    Comparator = "<="
+	Contains       Comparator = "CONTAINS"
+	StartsWith     Comparator = "STARTS WITH"
+	EndsWith       Comparator = "ENDS WITH"
+	Matches        Comparator = "MATCHES"
+	In             Comparator = "IN"
+	NotIn          Comparator = "NOT IN"
+	Between        Comparator = "BETWEEN"
+	NotBetween     Comparator = "NOT BETWEEN"
+	IsNull         Comparator = "IS NULL"
+	IsNotNull      Comparator = "IS NOT NULL"
+	IsEmpty        Comparator = "IS EMPTY"
+	IsNotEmpty     Comparator = "IS NOT EMPTY"
+)
+
+// comparatorTokens maps every accepted spelling to its Comparator. Two
+// character tokens are matched before their one character prefixes.
+var comparatorTokens = []struct {
+	token      string
+	comparator Comparator
+}{
+	{"==", Equal},
+	{"!=", NotEqual},
+	{"<>", NotEqual},
+	{">=", GreaterOrEqual},
+	{"<=", LessOrEqual},
+	{"=", Equal},
+	{">", Greater},
+	{"<", Less},
+}
+
+// keywordComparators are the comparators spelled as words. They must be
+// separated from the column name by whitespace and are case-insensitive.
+var keywordComparators = []struct {
+	keywords   []string
+	comparator Comparator
+}{
+	{[]string{"CONTAINS"}, Contains},
+	{[]string{"STARTS", "WITH"}, StartsWith},
+	{[]string{"ENDS", "WITH"}, EndsWith},
+	{[]string{"MATCHES"}, Matches},
+	{[]string{"IN"}, In},
+	{[]string{"NOT", "IN"}, NotIn},
+	{[]string{"BETWEEN"}, Between},
+	{[]string{"NOT", "BETWEEN"}, NotBetween},
+	{[]string{"IS", "NULL"}, IsNull},
+	{[]string{"IS", "NOT", "NULL"}, IsNotNull},
+	{[]string{"IS", "EMPTY"}, IsEmpty},
+	{[]string{"IS", "NOT", "EMPTY"}, IsNotEmpty},
+}
+
 type Filter struct {
 	column     string
-	comparator byte
+	comparator Comparator
 	value      string
+	columnType ColumnType
+	collation  Collation
+	pattern    *regexp.Regexp
+	upper      string
+	values     map[string]struct{}
+	// key is the collation key of value that CONTAINS, STARTS WITH and
+	// ENDS WITH look for.
+	key string
+	// valueColumn and upperColumn, when set, name the columns whose
+	// values replace value and upper in each row.
+	valueColumn string
+	upperColumn string
 }
 
-func NewFilter(column string, comparator byte, value string) *Filter {
+func NewFilter(column string, comparator Comparator, value string) *Filter {
 	return &Filter{
 		column:     column,
 		comparator: comparator,
 		value:      value,
+		key:        value,
 	}
 }
 
-func ParseFilters(f string, h CsvHeader) ([]Filter, error) {
-	if f == "" {
+// ParseFilters compiles the row filter definitions in f. Every non-blank
+// line is an expression of comparisons combined with AND, OR, NOT and
+// parentheses, e.g. "(status=open OR status=pending) AND NOT owner=bot";
+// a row must satisfy all lines. Besides the usual comparison operators,
+// "name CONTAINS Ltd", "code STARTS WITH BR-", "code ENDS WITH -X" and
+// "code MATCHES ^[A-Z]{2}-[0-9]+$" match substrings and regular
+// expressions, "id IN (1, 2, 3)", "id NOT IN FILE 'ids.txt'" test set
+// membership and "amount BETWEEN 10 AND 20" an inclusive range. Keywords
+// are case-insensitive.
+//
+// "col IS NULL" matches a field that is missing from a row shorter than the
+// header or whose value is one of the header's null tokens (by default only
+// the empty string); "col IS EMPTY" matches a field that is present and
+// empty. Their NOT forms require the field to be present. Every other
+// comparison fails on a missing field.
+//
+// A column name enclosed in backquotes on the right-hand side compares two
+// columns of the same row, as in "shipped_date > `order_date`".
+//
+// Column names and string values are compared using the header's
+// collation unless a comparison ends with its own, as in "name = joão
+// COLLATE NOCASE". Values run up to the next AND, OR or unmatched closing
+// parenthesis and are trimmed; quote them with '...' or "..." to keep
+// those characters. It returns nil if f holds no definitions. Errors are
+// located in f under the name "RowFilterDefinitions".
+func ParseFilters(f string, h CsvHeader) (FilterExpr, error) {
+	var exprs AndExpr
+	lines := strings.Split(f, "\n")
+	offset := 0
+	for i, line := range lines {
+		if i > 0 {
+			offset += len(lines[i-1]) + 1
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p := &filterParser{line: line, header: h}
+		expr, err := p.parse()
+		if err != nil {
+			return nil, locate(err, "RowFilterDefinitions", i+1, 1, int64(offset), 0)
+		}
+		exprs = append(exprs, expr)
+	}
+	switch len(exprs) {
+	case 0:
 		return nil, nil
+	case 1:
+		return exprs[0], nil
+	default:
+		return exprs, nil
 	}
-	var filters []Filter
-	lines := strings.Split(f, "\n")
-	for _, line := range lines {
-		filter, err := parseFilter(line, h)
+}
+
+// filterParser is a recursive descent parser for a single line of filter
+// definitions:
+//
+//	expr       = and { "OR" and }
+//	and        = not { "AND" not }
+//	not        = "NOT" not | "(" expr ")" | comparison
+//	comparison = predicate [ "COLLATE" ( "BINARY" | "NFC" | "NOCASE" ) ]
+//	predicate  = column comparator operand
+//	           | column ["NOT"] "IN" ( "(" value { "," value } ")" | "FILE" value )
+//	           | column ["NOT"] "BETWEEN" operand "AND" operand
+//	           | column "IS" ["NOT"] ( "NULL" | "EMPTY" )
+//	operand    = value | "`" column "`"
+//	comparator = "=" | "==" | "!=" | "<>" | ">" | ">=" | "<" | "<="
+//	           | "CONTAINS" | "STARTS WITH" | "ENDS WITH" | "MATCHES"
+type filterParser struct {
+	line   string
+	pos    int
+	depth  int
+	header CsvHeader
+}
+
+func (p *filterParser) parse() (FilterExpr, error) {
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.line) {
+		return nil, p.errorf("unexpected '%s'", p.line[p.pos:])
+	}
+	return expr, nil
+}
+
+func (p *filterParser) parseOr() (FilterExpr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := OrExpr{expr}
+	for p.acceptKeyword("OR") {
+		expr, err := p.parseAnd()
 		if err != nil {
 			return nil, err
 		}
-		if filter != nil {
-			filters = append(filters, *filter)
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *filterParser) parseAnd() (FilterExpr, error) {
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	exprs := AndExpr{expr}
+	for p.acceptKeyword("AND") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
 		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
 	}
-	return filters, nil
+	return exprs, nil
 }
 
-func parseFilter(line string, h CsvHeader) (*Filter, error) {
-	for i, char := range line {
-		if char == '=' || char == '>' || char == '<' {
-			col := line[:i]
-			val := line[i+1:]
-			if h.Contains(col) {
-				return NewFilter(col, byte(char), val), nil
-			} else {
-				return nil, fmt.Errorf("Header '%s' not found in CSV file/string", col)
-			}
+func (p *filterParser) parseNot() (FilterExpr, error) {
+	if p.acceptKeyword("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return NotExpr{Expr: expr}, nil
+	}
+	p.skipSpaces()
+	if p.pos < len(p.line) && p.line[p.pos] == '(' {
+		p.pos++
+		p.depth++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
 		}
+		p.skipSpaces()
+		if p.pos >= len(p.line) || p.line[p.pos] != ')' {
+			return nil, p.errorf("missing ')'")
+		}
+		p.pos++
+		p.depth--
+		return expr, nil
 	}
-	return nil, fmt.Errorf("Header '%s' not found in CSV file/string", line)
+	return p.parseComparison()
 }
 
-func applyFilter(value string, filter Filter) bool {
-	switch filter.comparator {
-	case '=':
-		return strings.Compare(value, filter.value) == 0
-	case '>':
-		return strings.Compare(value, filter.value) > 0
-	case '<':
-		return strings.Compare(value, filter.value) < 0
+// parseComparison parses "<column> <comparator> <value>". The column ends
+// at the first operator character, or at whitespace followed by a word
+// comparator, and the longest comparator starting there is used, so "a>=5"
+// compares column "a" with ">=" to "5". A column name enclosed in
+// backquotes may contain any character.
+func (p *filterParser) parseComparison() (FilterExpr, error) {
+	var col string
+	colPos := p.pos
+	if p.atColumnReference() {
+		var err error
+		if col, err = p.parseColumnReference(); err != nil {
+			return nil, err
+		}
+	} else {
+		start := p.pos
+		end := p.pos
+		for end < len(p.line) && !strings.ContainsRune("=!<>()", rune(p.line[end])) && !p.atBoundary(end) && !p.atKeywordComparator(end) {
+			end++
+		}
+		col = strings.TrimSpace(p.line[start:end])
+		p.pos = end
+	}
+	p.skipSpaces()
+
+	comparator, err := p.parseComparator()
+	if err != nil {
+		return nil, err
+	}
+	if col == "" {
+		return nil, p.errorf("missing column name")
+	}
+	filter := NewFilter(col, comparator, "")
+
+	// The literal values and where they start in the line, so that an
+	// invalid one is reported at its own position.
+	var values []string
+	var positions []int
+	var valuePos, upperPos int
+	switch comparator {
+	case IsNull, IsNotNull, IsEmpty, IsNotEmpty:
+	case In, NotIn:
+		if values, positions, err = p.parseValueList(); err != nil {
+			return nil, err
+		}
+	case Between, NotBetween:
+		p.skipSpaces()
+		valuePos = p.pos
+		if filter.value, filter.valueColumn, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if !p.acceptKeyword("AND") {
+			return nil, p.errorf("missing AND in %s", comparator)
+		}
+		p.skipSpaces()
+		upperPos = p.pos
+		if filter.upper, filter.upperColumn, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
 	default:
+		p.skipSpaces()
+		valuePos = p.pos
+		if filter.value, filter.valueColumn, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if comparator == Matches && filter.valueColumn != "" {
+			return nil, p.errorf("MATCHES requires a literal pattern")
+		}
+	}
+	if filter.valueColumn == "" && comparator != In && comparator != NotIn {
+		values = append(values, filter.value)
+		positions = append(positions, valuePos)
+	}
+	if filter.upperColumn == "" && (comparator == Between || comparator == NotBetween) {
+		values = append(values, filter.upper)
+		positions = append(positions, upperPos)
+	}
+
+	filter.collation = p.header.collation
+	if p.acceptKeyword("COLLATE") {
+		if filter.collation, err = p.parseCollation(); err != nil {
+			return nil, err
+		}
+	}
+	columnIndex := p.header.indexOf(col, filter.collation)
+	if columnIndex == -1 {
+		return nil, &UnknownColumnError{Position: Position{Offset: int64(colPos)}, Column: col}
+	}
+	filter.column = p.header.headers[columnIndex]
+	filter.columnType = p.header.columnTypes[filter.column]
+	refs := []struct {
+		column *string
+		pos    int
+	}{{&filter.valueColumn, valuePos}, {&filter.upperColumn, upperPos}}
+	for _, ref := range refs {
+		if *ref.column == "" {
+			continue
+		}
+		i := p.header.indexOf(*ref.column, filter.collation)
+		if i == -1 {
+			return nil, &UnknownColumnError{Position: Position{Offset: int64(ref.pos)}, Column: *ref.column}
+		}
+		*ref.column = p.header.headers[i]
+		if filter.columnType == TypeAuto {
+			filter.columnType = p.header.columnTypes[*ref.column]
+		}
+	}
+
+	switch comparator {
+	case IsNull, IsNotNull:
+		nullTokens := p.header.nullTokens
+		if nullTokens == nil {
+			nullTokens = []string{""}
+		}
+		filter.values = make(map[string]struct{}, len(nullTokens))
+		for _, token := range nullTokens {
+			filter.values[filter.collation.key(token)] = struct{}{}
+		}
+	case In, NotIn:
+		if filter.columnType == TypeAuto {
+			filter.columnType = equalityType(values)
+		}
+		filter.values = make(map[string]struct{}, len(values))
+		for i, val := range values {
+			key, ok := canonicalValue(val, filter.columnType, filter.collation)
+			if !ok {
+				return nil, p.errorAt(positions[i], "'%s' is not a valid %s", val, filter.columnType)
+			}
+			filter.values[key] = struct{}{}
+		}
+	case Contains, StartsWith, EndsWith:
+		filter.key = filter.collation.key(filter.value)
+	case IsEmpty, IsNotEmpty:
+	case Matches:
+		// Folding would corrupt escapes such as \D, so the pattern is only
+		// normalised and case is ignored through the (?i) flag instead.
+		// Values are matched unfolded too, as folding may expand them.
+		pattern := filter.value
+		if filter.collation != CollateBinary {
+			pattern = CollateNFC.key(pattern)
+		}
+		if filter.collation == CollateNoCase {
+			pattern = "(?i)" + pattern
+		}
+		if filter.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, p.errorAt(valuePos, "invalid regular expression: %v", err)
+		}
+	default:
+		switch {
+		case filter.columnType != TypeAuto:
+		case comparator == Equal || comparator == NotEqual:
+			filter.columnType = equalityType(values)
+		default:
+			filter.columnType = literalType(values)
+		}
+		for i, val := range values {
+			if !filter.columnType.isValid(val) {
+				return nil, p.errorAt(positions[i], "'%s' is not a valid %s", val, filter.columnType)
+			}
+		}
+	}
+	return *filter, nil
+}
+
+func (p *filterParser) parseCollation() (Collation, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.line) && !isSpace(p.line[p.pos]) && p.line[p.pos] != ')' {
+		p.pos++
+	}
+	collation, ok := parseCollation(p.line[start:p.pos])
+	if !ok {
+		return 0, p.errorAt(start, "unknown collation '%s'", p.line[start:p.pos])
+	}
+	return collation, nil
+}
+
+func (p *filterParser) parseComparator() (Comparator, error) {
+	start := p.pos
+	for _, t := range comparatorTokens {
+		if strings.HasPrefix(p.line[p.pos:], t.token) {
+			p.pos += len(t.token)
+			if p.pos < len(p.line) && strings.ContainsRune("=!<>", rune(p.line[p.pos])) {
+				return "", p.errorAt(start, "unknown comparison operator '%s'", p.line[start:p.pos+1])
+			}
+			return t.comparator, nil
+		}
+	}
+	if p.pos < len(p.line) && strings.ContainsRune("=!<>", rune(p.line[p.pos])) {
+		return "", p.errorf("unknown comparison operator '%s'", p.line[p.pos:p.pos+1])
+	}
+	for _, t := range keywordComparators {
+		if end := p.keywordsAt(p.pos, t.keywords); end >= 0 {
+			p.pos = end
+			return t.comparator, nil
+		}
+	}
+	return "", p.errorf("missing comparison operator")
+}
+
+// atKeywordComparator reports whether a word comparator starts after the
+// whitespace at i.
+func (p *filterParser) atKeywordComparator(i int) bool {
+	if !isSpace(p.line[i]) {
 		return false
 	}
+	for i < len(p.line) && isSpace(p.line[i]) {
+		i++
+	}
+	for _, t := range keywordComparators {
+		if p.keywordsAt(i, t.keywords) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// keywordsAt returns the position after the whitespace separated keywords
+// starting at i, or -1 if they do not start there.
+func (p *filterParser) keywordsAt(i int, keywords []string) int {
+	for k, keyword := range keywords {
+		if k > 0 {
+			if i >= len(p.line) || !isSpace(p.line[i]) {
+				return -1
+			}
+			for i < len(p.line) && isSpace(p.line[i]) {
+				i++
+			}
+		}
+		if !p.keywordAt(i, keyword) {
+			return -1
+		}
+		i += len(keyword)
+	}
+	return i
+}
+
+// parseOperand parses either a column reference, returned as column, or a
+// literal value.
+func (p *filterParser) parseOperand() (value string, column string, err error) {
+	p.skipSpaces()
+	if p.atColumnReference() {
+		column, err = p.parseColumnReference()
+		if err == nil && column == "" {
+			err = p.errorf("missing column name")
+		}
+		return "", column, err
+	}
+	value, err = p.parseValue()
+	return value, "", err
+}
+
+func (p *filterParser) atColumnReference() bool {
+	return p.pos < len(p.line) && p.line[p.pos] == '`'
+}
+
+// parseColumnReference parses a column name enclosed in backquotes, in
+// which a backquote is escaped by doubling it.
+func (p *filterParser) parseColumnReference() (string, error) {
+	col, err := p.parseQuoted()
+	if err != nil {
+		return "", p.errorf("unterminated column name")
+	}
+	return col, nil
 }
 
-func applyFilters(row []string, filters []Filter, csvHeader CsvHeader) bool {
-	for _, filter := range filters {
-		columnIndex := -1
-		for i, header := range csvHeader.headers {
-			if header == filter.column {
-				columnIndex = i
+// parseValue parses a quoted value or a bare value running up to the next
+// AND, OR or closing parenthesis of a group.
+func (p *filterParser) parseValue() (string, error) {
+	p.skipSpaces()
+	if p.atQuote() {
+		return p.parseQuoted()
+	}
+	start := p.pos
+	nested := 0
+	for ; p.pos < len(p.line) && !p.atBoundary(p.pos); p.pos++ {
+		if c := p.line[p.pos]; c == '(' {
+			nested++
+		} else if c == ')' {
+			if nested == 0 {
 				break
 			}
+			nested--
 		}
-		if columnIndex == -1 || !applyFilter(row[columnIndex], filter) {
-			return false
+	}
+	if p.pos < len(p.line) && p.line[p.pos] == ')' && p.depth == 0 {
+		return "", p.errorf("unexpected ')'")
+	}
+	return strings.TrimSpace(p.line[start:p.pos]), nil
+}
+
+// parseValueList parses "(v1, v2, ...)", whose bare values run up to the
+// next comma or closing parenthesis, or "FILE <path>", which reads one
+// value per line from the file at path.
+func (p *filterParser) parseValueList() ([]string, []int, error) {
+	if p.acceptKeyword("FILE") {
+		p.skipSpaces()
+		pathPos := p.pos
+		path, err := p.parseValue()
+		if err != nil {
+			return nil, nil, err
+		}
+		values, err := readValuesFile(path)
+		positions := make([]int, len(values))
+		for i := range positions {
+			positions[i] = pathPos
+		}
+		return values, positions, err
+	}
+
+	p.skipSpaces()
+	if p.pos >= len(p.line) || p.line[p.pos] != '(' {
+		return nil, nil, p.errorf("missing '(' after IN")
+	}
+	p.pos++
+	p.skipSpaces()
+	if p.pos < len(p.line) && p.line[p.pos] == ')' {
+		p.pos++
+		return nil, nil, nil
+	}
+
+	var values []string
+	var positions []int
+	for {
+		p.skipSpaces()
+		positions = append(positions, p.pos)
+		var val string
+		if p.atQuote() {
+			var err error
+			if val, err = p.parseQuoted(); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			start := p.pos
+			for p.pos < len(p.line) && p.line[p.pos] != ',' && p.line[p.pos] != ')' {
+				p.pos++
+			}
+			val = strings.TrimSpace(p.line[start:p.pos])
+		}
+		values = append(values, val)
+
+		p.skipSpaces()
+		switch {
+		case p.pos >= len(p.line):
+			return nil, nil, p.errorf("missing ')'")
+		case p.line[p.pos] == ',':
+			p.pos++
+		case p.line[p.pos] == ')':
+			p.pos++
+			return values, positions, nil
+		default:
+			return nil, nil, p.errorf("unexpected '%s'", p.line[p.pos:])
+		}
+	}
+}
+
+func (p *filterParser) atQuote() bool {
+	return p.pos < len(p.line) && (p.line[p.pos] == '"' || p.line[p.pos] == '\'')
+}
+
+// parseQuoted parses a value enclosed in single or double quotes, in which
+// the quote is escaped by doubling it.
+func (p *filterParser) parseQuoted() (string, error) {
+	quote := p.line[p.pos]
+	var val strings.Builder
+	for i := p.pos + 1; i < len(p.line); i++ {
+		if p.line[i] != quote {
+			val.WriteByte(p.line[i])
+			continue
+		}
+		if i+1 < len(p.line) && p.line[i+1] == quote {
+			val.WriteByte(quote)
+			i++
+			continue
+		}
+		p.pos = i + 1
+		return val.String(), nil
+	}
+	return "", p.errorf("unterminated quoted value")
+}
+
+// readValuesFile reads the values of an IN list from path, one per line.
+// Surrounding whitespace and blank lines are ignored.
+func readValuesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read filter values file %s: %w", path, err)
+	}
+	var values []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if val := strings.TrimSpace(line); val != "" {
+			values = append(values, val)
 		}
 	}
+	return values, nil
+}
+
+// atBoundary reports whether the AND, OR or COLLATE keyword starts after
+// the whitespace at i.
+func (p *filterParser) atBoundary(i int) bool {
+	if !isSpace(p.line[i]) {
+		return false
+	}
+	for i < len(p.line) && isSpace(p.line[i]) {
+		i++
+	}
+	return p.keywordAt(i, "AND") || p.keywordAt(i, "OR") || p.keywordAt(i, "COLLATE")
+}
+
+// keywordAt reports whether keyword, in any case, starts at i and is
+// followed by whitespace, a parenthesis or the end of the line.
+func (p *filterParser) keywordAt(i int, keyword string) bool {
+	end := i + len(keyword)
+	if end > len(p.line) || !strings.EqualFold(p.line[i:end], keyword) {
+		return false
+	}
+	return end == len(p.line) || isSpace(p.line[end]) || p.line[end] == '(' || p.line[end] == ')'
+}
+
+func (p *filterParser) acceptKeyword(keyword string) bool {
+	p.skipSpaces()
+	if !p.keywordAt(p.pos, keyword) {
+		return false
+	}
+	p.pos += len(keyword)
 	return true
 }
+
+func (p *filterParser) skipSpaces() {
+	for p.pos < len(p.line) && isSpace(p.line[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+// errorAt returns a FilterSyntaxError located at pos of the line.
+func (p *filterParser) errorAt(pos int, format string, args ...any) error {
+	return &FilterSyntaxError{Position: Position{Offset: int64(pos)}, Definition: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
+
+func applyFilter(value string, filter Filter) bool {
+	switch filter.comparator {
+	case Contains:
+		return strings.Contains(filter.collation.key(value), filter.key)
+	case StartsWith:
+		return strings.HasPrefix(filter.collation.key(value), filter.key)
+	case EndsWith:
+		return strings.HasSuffix(filter.collation.key(value), filter.key)
+	case Matches:
+		if filter.collation != CollateBinary {
+			value = CollateNFC.key(value)
+		}
+		return filter.pattern != nil && filter.pattern.MatchString(value)
+	case IsNull, IsNotNull:
+		_, found := filter.values[filter.collation.key(value)]
+		return found == (filter.comparator == IsNull)
+	case IsEmpty:
+		return value == ""
+	case IsNotEmpty:
+		return value != ""
+	case In, NotIn:
+		key, ok := canonicalValue(value, filter.columnType, filter.collation)
+		if !ok {
+			return false
+		}
+		_, found := filter.values[key]
+		return found == (filter.comparator == In)
+	case Between, NotBetween:
+		lower, okLower := compareValues(value, filter.value, filter.columnType, filter.collation)
+		upper, okUpper := compareValues(value, filter.upper, filter.columnType, filter.collation)
+		if !okLower || !okUpper {
+			return false
+		}
+		return (lower >= 0 && upper <= 0) == (filter.comparator == Between)
+	}
+	c, ok := compareValues(value, filter.value, filter.columnType, filter.collation)
+	if !ok {
+		return false
+	}
+	switch filter.comparator {
+	case Equal:
+		return c == 0
+	case NotEqual:
+		return c != 0
+	case Greater:
+		return c > 0
+	case GreaterOrEqual:
+		return c >= 0
+	case Less:
+		return c < 0
+	case LessOrEqual:
+		return c <= 0
+	default:
+		return false
+	}
+}
+
+func applyFilters(row []string, filters FilterExpr, csvHeader CsvHeader) bool {
+	return filters == nil || filters.match(row, csvHeader)
+}
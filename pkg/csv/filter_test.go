@@ -1,8 +1,9 @@
 package csv
 
 import (
-	"fmt"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"regexp"
 	"testing"
 )
 
@@ -11,25 +12,274 @@ func TestParseFilters(t *testing.T) {
 		name                 string
 		header               CsvHeader
 		rowFilterDefinitions string
-		expected             []Filter
+		expected             FilterExpr
 	}{
 		{
 			name:                 "Single filter",
 			header:               CsvHeader{headers: []string{"header1"}},
 			rowFilterDefinitions: "header1=1",
-			expected:             []Filter{{column: "header1", comparator: '=', value: "1"}},
+			expected:             Filter{column: "header1", comparator: Equal, value: "1", columnType: TypeDecimal},
 		},
 		{
 			name:                 "Multiple filters",
 			header:               CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			rowFilterDefinitions: "header1=1\nheader2>2\nheader3<3",
-			expected:             []Filter{{column: "header1", comparator: '=', value: "1"}, {column: "header2", comparator: '>', value: "2"}, {column: "header3", comparator: '<', value: "3"}},
+			expected:             AndExpr{Filter{column: "header1", comparator: Equal, value: "1", columnType: TypeDecimal}, Filter{column: "header2", comparator: Greater, value: "2", columnType: TypeDecimal}, Filter{column: "header3", comparator: Less, value: "3", columnType: TypeDecimal}},
+		},
+		{
+			name:                 "Two character operators",
+			header:               CsvHeader{headers: []string{"a", "b", "c", "d", "e"}},
+			rowFilterDefinitions: "a>=5\nb<=5\nc!=5\nd<>5\ne==5",
+			expected: AndExpr{
+				Filter{column: "a", comparator: GreaterOrEqual, value: "5", columnType: TypeDecimal},
+				Filter{column: "b", comparator: LessOrEqual, value: "5", columnType: TypeDecimal},
+				Filter{column: "c", comparator: NotEqual, value: "5", columnType: TypeDecimal},
+				Filter{column: "d", comparator: NotEqual, value: "5", columnType: TypeDecimal},
+				Filter{column: "e", comparator: Equal, value: "5", columnType: TypeDecimal},
+			},
+		},
+		{
+			name:                 "Zero-padded numbers",
+			header:               CsvHeader{headers: []string{"zip"}},
+			rowFilterDefinitions: "zip=01234 OR zip!=-007 OR zip>=01000",
+			expected: OrExpr{
+				Filter{column: "zip", comparator: Equal, value: "01234", columnType: TypeString},
+				Filter{column: "zip", comparator: NotEqual, value: "-007", columnType: TypeString},
+				Filter{column: "zip", comparator: GreaterOrEqual, value: "01000", columnType: TypeDecimal},
+			},
+		},
+		{
+			name:                 "Empty value and blank lines",
+			header:               CsvHeader{headers: []string{"header1", "header2"}},
+			rowFilterDefinitions: "header1=\n\nheader2!=\n",
+			expected:             AndExpr{Filter{column: "header1", comparator: Equal, value: "", columnType: TypeString}, Filter{column: "header2", comparator: NotEqual, value: "", columnType: TypeString}},
+		},
+		{
+			name:                 "Declared column type",
+			header:               CsvHeader{headers: []string{"header1"}, columnTypes: map[string]ColumnType{"header1": TypeDecimal}},
+			rowFilterDefinitions: "header1>1.5",
+			expected:             Filter{column: "header1", comparator: Greater, value: "1.5", columnType: TypeDecimal},
 		},
 		{
 			name:                 "Empty filter definitions",
 			header:               CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			rowFilterDefinitions: "",
-			expected:             []Filter(nil),
+			expected:             nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFilters(tt.rowFilterDefinitions, tt.header)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseFilterExpressions(t *testing.T) {
+	header := CsvHeader{headers: []string{"status", "owner", "name"}}
+	open := Filter{column: "status", comparator: Equal, value: "open", columnType: TypeString}
+	pending := Filter{column: "status", comparator: Equal, value: "pending", columnType: TypeString}
+	bot := Filter{column: "owner", comparator: Equal, value: "bot", columnType: TypeString}
+	tests := []struct {
+		name                 string
+		rowFilterDefinitions string
+		expected             FilterExpr
+	}{
+		{
+			name:                 "OR",
+			rowFilterDefinitions: "status=open OR status=pending",
+			expected:             OrExpr{open, pending},
+		},
+		{
+			name:                 "AND binds tighter than OR",
+			rowFilterDefinitions: "status=open or status=pending and owner=bot",
+			expected:             OrExpr{open, AndExpr{pending, bot}},
+		},
+		{
+			name:                 "Parentheses",
+			rowFilterDefinitions: "(status=open OR status=pending) AND owner=bot",
+			expected:             AndExpr{OrExpr{open, pending}, bot},
+		},
+		{
+			name:                 "NOT",
+			rowFilterDefinitions: "NOT owner=bot AND NOT (status=open)",
+			expected:             AndExpr{NotExpr{Expr: bot}, NotExpr{Expr: open}},
+		},
+		{
+			name:                 "Lines are combined with AND",
+			rowFilterDefinitions: "status=open OR status=pending\nowner=bot",
+			expected:             AndExpr{OrExpr{open, pending}, bot},
+		},
+		{
+			name:                 "Spaces around comparison",
+			rowFilterDefinitions: "  status = open  ",
+			expected:             open,
+		},
+		{
+			name:                 "Bare value with spaces and commas",
+			rowFilterDefinitions: "name=Doe, John OR name=Ltd (BR)",
+			expected:             OrExpr{Filter{column: "name", comparator: Equal, value: "Doe, John", columnType: TypeString}, Filter{column: "name", comparator: Equal, value: "Ltd (BR)", columnType: TypeString}},
+		},
+		{
+			name:                 "Bare value with parentheses in a group",
+			rowFilterDefinitions: "(name=Ltd (BR)) OR name=')'",
+			expected:             OrExpr{Filter{column: "name", comparator: Equal, value: "Ltd (BR)", columnType: TypeString}, Filter{column: "name", comparator: Equal, value: ")", columnType: TypeString}},
+		},
+		{
+			name:                 "Quoted values",
+			rowFilterDefinitions: "name=\"Rock and Roll\" OR name='it''s (ok)'",
+			expected:             OrExpr{Filter{column: "name", comparator: Equal, value: "Rock and Roll", columnType: TypeString}, Filter{column: "name", comparator: Equal, value: "it's (ok)", columnType: TypeString}},
+		},
+		{
+			name:                 "Pattern comparators",
+			rowFilterDefinitions: "name contains Ltd OR name STARTS  WITH BR- OR name ends with '-X' OR owner MATCHES ^b.t$",
+			expected: OrExpr{
+				Filter{column: "name", comparator: Contains, value: "Ltd", key: "Ltd"},
+				Filter{column: "name", comparator: StartsWith, value: "BR-", key: "BR-"},
+				Filter{column: "name", comparator: EndsWith, value: "-X", key: "-X"},
+				Filter{column: "owner", comparator: Matches, value: "^b.t$", pattern: regexp.MustCompile("^b.t$")},
+			},
+		},
+		{
+			name:                 "Extra whitespace around word comparator",
+			rowFilterDefinitions: "NOT  name  contains  x",
+			expected:             NotExpr{Expr: Filter{column: "name", comparator: Contains, value: "x", key: "x"}},
+		},
+		{
+			name:                 "IN and NOT IN",
+			rowFilterDefinitions: "status IN (open, 'on hold', \"a,b\") AND owner not in ()",
+			expected: AndExpr{
+				Filter{column: "status", comparator: In, columnType: TypeString, values: map[string]struct{}{"open": {}, "on hold": {}, "a,b": {}}},
+				Filter{column: "owner", comparator: NotIn, values: map[string]struct{}{}},
+			},
+		},
+		{
+			name:                 "IN with numbers",
+			rowFilterDefinitions: "status IN (7, 1.50, -2)",
+			expected:             Filter{column: "status", comparator: In, columnType: TypeDecimal, values: map[string]struct{}{"7": {}, "1.5": {}, "-2": {}}},
+		},
+		{
+			name:                 "IN with zero-padded numbers compares strings",
+			rowFilterDefinitions: "status IN (01234, 98765)",
+			expected:             Filter{column: "status", comparator: In, columnType: TypeString, values: map[string]struct{}{"01234": {}, "98765": {}}},
+		},
+		{
+			name:                 "IN with dates",
+			rowFilterDefinitions: "status IN (2024-01-02, 02/01/2024 10:00:00)",
+			expected:             Filter{column: "status", comparator: In, columnType: TypeTimestamp, values: map[string]struct{}{"2024-01-02T00:00:00Z": {}, "2024-01-02T10:00:00Z": {}}},
+		},
+		{
+			name:                 "IN with numbers and dates compares strings",
+			rowFilterDefinitions: "status IN (007, 2024-01-02)",
+			expected:             Filter{column: "status", comparator: In, columnType: TypeString, values: map[string]struct{}{"007": {}, "2024-01-02": {}}},
+		},
+		{
+			name:                 "BETWEEN",
+			rowFilterDefinitions: "status BETWEEN a AND c AND owner NOT BETWEEN 'x' and 'z' OR name=n",
+			expected: OrExpr{
+				AndExpr{
+					Filter{column: "status", comparator: Between, value: "a", upper: "c", columnType: TypeString},
+					Filter{column: "owner", comparator: NotBetween, value: "x", upper: "z", columnType: TypeString},
+				},
+				Filter{column: "name", comparator: Equal, value: "n", columnType: TypeString},
+			},
+		},
+		{
+			name:                 "NULL and EMPTY",
+			rowFilterDefinitions: "status IS NULL OR status is not null OR owner IS EMPTY OR owner IS NOT EMPTY",
+			expected: OrExpr{
+				Filter{column: "status", comparator: IsNull, values: map[string]struct{}{"": {}}},
+				Filter{column: "status", comparator: IsNotNull, values: map[string]struct{}{"": {}}},
+				Filter{column: "owner", comparator: IsEmpty},
+				Filter{column: "owner", comparator: IsNotEmpty},
+			},
+		},
+		{
+			name:                 "Keyword inside a word",
+			rowFilterDefinitions: "name=ORANGE AND owner=ANDREW",
+			expected:             AndExpr{Filter{column: "name", comparator: Equal, value: "ORANGE", columnType: TypeString}, Filter{column: "owner", comparator: Equal, value: "ANDREW", columnType: TypeString}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFilters(tt.rowFilterDefinitions, header)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseFiltersInFile(t *testing.T) {
+	file, err := os.CreateTemp("", "values.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+	if _, err := file.WriteString("1001\r\n 1002 \n\n1003\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	header := CsvHeader{headers: []string{"account"}}
+	result, err := ParseFilters("account IN FILE '"+file.Name()+"'", header)
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{column: "account", comparator: In, columnType: TypeDecimal, values: map[string]struct{}{"1001": {}, "1002": {}, "1003": {}}}, result)
+
+	_, err = ParseFilters("account IN FILE '"+file.Name()+".missing'", header)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	header.columnTypes = map[string]ColumnType{"account": TypeTimestamp}
+	_, err = ParseFilters("account IN FILE  '"+file.Name()+"'", header)
+	assert.Equal(t, &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 18, Offset: 17}, Definition: "account IN FILE  '" + file.Name() + "'", Msg: "'1001' is not a valid timestamp"}, err)
+}
+
+func TestParseFiltersNullTokens(t *testing.T) {
+	header := CsvHeader{headers: []string{"amount"}, nullTokens: []string{"NULL", "N/A"}}
+	result, err := ParseFilters("amount IS NULL", header)
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{column: "amount", comparator: IsNull, values: map[string]struct{}{"NULL": {}, "N/A": {}}}, result)
+}
+
+func TestParseFiltersCollation(t *testing.T) {
+	tests := []struct {
+		name                 string
+		header               CsvHeader
+		rowFilterDefinitions string
+		expected             FilterExpr
+	}{
+		{
+			name:                 "Header collation resolves column names",
+			header:               CsvHeader{headers: []string{"Situa\u00e7\u00e3o"}, collation: CollateNoCase},
+			rowFilterDefinitions: "SITUAC\u0327A\u0303O = aberto",
+			expected:             Filter{column: "Situa\u00e7\u00e3o", comparator: Equal, value: "aberto", collation: CollateNoCase, columnType: TypeString},
+		},
+		{
+			name:                 "COLLATE overrides header collation",
+			header:               CsvHeader{headers: []string{"status"}},
+			rowFilterDefinitions: "STATUS = Open COLLATE nocase OR status = x collate BINARY",
+			expected: OrExpr{
+				Filter{column: "status", comparator: Equal, value: "Open", collation: CollateNoCase, columnType: TypeString},
+				Filter{column: "status", comparator: Equal, value: "x", collation: CollateBinary, columnType: TypeString},
+			},
+		},
+		{
+			name:                 "COLLATE applies to IN lists",
+			header:               CsvHeader{headers: []string{"status"}},
+			rowFilterDefinitions: "status IN (Open, PENDING) COLLATE NOCASE",
+			expected:             Filter{column: "status", comparator: In, columnType: TypeString, collation: CollateNoCase, values: map[string]struct{}{"open": {}, "pending": {}}},
+		},
+		{
+			name:                 "COLLATE NOCASE makes patterns case-insensitive",
+			header:               CsvHeader{headers: []string{"status"}},
+			rowFilterDefinitions: "status MATCHES ^\\D+$ COLLATE NOCASE",
+			expected:             Filter{column: "status", comparator: Matches, value: "^\\D+$", collation: CollateNoCase, pattern: regexp.MustCompile("(?i)^\\D+$")},
 		},
 	}
 
@@ -42,6 +292,44 @@ func TestParseFilters(t *testing.T) {
 	}
 }
 
+func TestParseFiltersColumnReferences(t *testing.T) {
+	header := CsvHeader{headers: []string{"order date", "shipped", "low", "high"}, columnTypes: map[string]ColumnType{"shipped": TypeTimestamp}}
+	tests := []struct {
+		name                 string
+		rowFilterDefinitions string
+		expected             FilterExpr
+	}{
+		{
+			name:                 "Column on the right-hand side",
+			rowFilterDefinitions: "shipped > `order date`",
+			expected:             Filter{column: "shipped", comparator: Greater, valueColumn: "order date", columnType: TypeTimestamp},
+		},
+		{
+			name:                 "Backquoted column on the left-hand side",
+			rowFilterDefinitions: "`order date` <= `shipped`",
+			expected:             Filter{column: "order date", comparator: LessOrEqual, valueColumn: "shipped", columnType: TypeTimestamp},
+		},
+		{
+			name:                 "BETWEEN column bounds",
+			rowFilterDefinitions: "shipped BETWEEN `low` AND 2024-12-31",
+			expected:             Filter{column: "shipped", comparator: Between, valueColumn: "low", upper: "2024-12-31", columnType: TypeTimestamp},
+		},
+		{
+			name:                 "Pattern against a column",
+			rowFilterDefinitions: "high CONTAINS `LOW` COLLATE NOCASE",
+			expected:             Filter{column: "high", comparator: Contains, valueColumn: "low", collation: CollateNoCase},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFilters(tt.rowFilterDefinitions, header)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
 func TestParseUnknownFilters(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -53,13 +341,181 @@ func TestParseUnknownFilters(t *testing.T) {
 			name:                 "Invalid filter format",
 			header:               CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			rowFilterDefinitions: "header1=1\nheader2>2\ninvalidfilter",
-			expected:             fmt.Errorf("Header 'invalidfilter' not found in CSV file/string"),
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 3, Column: 14, Offset: 33}, Definition: "invalidfilter", Msg: "missing comparison operator"},
+		},
+		{
+			name:                 "Unknown referenced column",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 = `header2`",
+			expected:             &UnknownColumnError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 11, Offset: 10}, Column: "header2"},
+		},
+		{
+			name:                 "Unknown referenced upper bound",
+			header:               CsvHeader{headers: []string{"header1", "header2"}},
+			rowFilterDefinitions: "header1 BETWEEN `header2` AND `header3`",
+			expected:             &UnknownColumnError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 31, Offset: 30}, Column: "header3"},
+		},
+		{
+			name:                 "Unterminated column reference",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 = `header1",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 11, Offset: 10}, Definition: "header1 = `header1", Msg: "unterminated column name"},
+		},
+		{
+			name:                 "Column reference in MATCHES",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 MATCHES `header1`",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 26, Offset: 25}, Definition: "header1 MATCHES `header1`", Msg: "MATCHES requires a literal pattern"},
+		},
+		{
+			name:                 "Invalid literal next to a column reference",
+			header:               CsvHeader{headers: []string{"header1", "header2"}, columnTypes: map[string]ColumnType{"header1": TypeInteger}},
+			rowFilterDefinitions: "header1 BETWEEN `header2` AND abc",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 31, Offset: 30}, Definition: "header1 BETWEEN `header2` AND abc", Msg: "'abc' is not a valid integer"},
 		},
 		{
 			name:                 "Invalid column name",
 			header:               CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			rowFilterDefinitions: "header1=1\nheader2>2\nheader4>0",
-			expected:             fmt.Errorf("Header 'header4' not found in CSV file/string"),
+			expected:             &UnknownColumnError{Position: Position{Source: "RowFilterDefinitions", Line: 3, Column: 1, Offset: 20}, Column: "header4"},
+		},
+		{
+			name:                 "Missing column name",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: ">=5",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 3, Offset: 2}, Definition: ">=5", Msg: "missing column name"},
+		},
+		{
+			name:                 "Lone exclamation mark",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1!5",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 8, Offset: 7}, Definition: "header1!5", Msg: "unknown comparison operator '!'"},
+		},
+		{
+			name:                 "Reversed operator",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1=>5",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 8, Offset: 7}, Definition: "header1=>5", Msg: "unknown comparison operator '=>'"},
+		},
+		{
+			name:                 "Three character operator",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1>==5",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 8, Offset: 7}, Definition: "header1>==5", Msg: "unknown comparison operator '>=='"},
+		},
+		{
+			name:                 "Missing closing parenthesis",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "(header1=1 OR header1=2",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 24, Offset: 23}, Definition: "(header1=1 OR header1=2", Msg: "missing ')'"},
+		},
+		{
+			name:                 "Stray closing parenthesis",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1=x OR header1=foo)",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 25, Offset: 24}, Definition: "header1=x OR header1=foo)", Msg: "unexpected ')'"},
+		},
+		{
+			name:                 "Dangling AND",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1=1 AND",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 14, Offset: 13}, Definition: "header1=1 AND", Msg: "missing comparison operator"},
+		},
+		{
+			name:                 "Unbalanced closing parenthesis",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "(header1=1))",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 12, Offset: 11}, Definition: "(header1=1))", Msg: "unexpected ')'"},
+		},
+		{
+			name:                 "Unterminated quoted value",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1='1",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 9, Offset: 8}, Definition: "header1='1", Msg: "unterminated quoted value"},
+		},
+		{
+			name:                 "Text after quoted value",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1='1' 2",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 13, Offset: 12}, Definition: "header1='1' 2", Msg: "unexpected '2'"},
+		},
+		{
+			name:                 "Invalid regular expression",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 matches a(b",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 17, Offset: 16}, Definition: "header1 matches a(b", Msg: "invalid regular expression: error parsing regexp: missing closing ): `a(b`"},
+		},
+		{
+			name:                 "Word comparator without column",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "contains x",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 11, Offset: 10}, Definition: "contains x", Msg: "missing comparison operator"},
+		},
+		{
+			name:                 "IN without list",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 IN a,b",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 12, Offset: 11}, Definition: "header1 IN a,b", Msg: "missing '(' after IN"},
+		},
+		{
+			name:                 "Unterminated IN list",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 IN (a,b",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 16, Offset: 15}, Definition: "header1 IN (a,b", Msg: "missing ')'"},
+		},
+		{
+			name:                 "Text after quoted IN value",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 IN ('a' b)",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 17, Offset: 16}, Definition: "header1 IN ('a' b)", Msg: "unexpected 'b)'"},
+		},
+		{
+			name:                 "BETWEEN without AND",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 BETWEEN 1 OR 2",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 19, Offset: 18}, Definition: "header1 BETWEEN 1 OR 2", Msg: "missing AND in BETWEEN"},
+		},
+		{
+			name:                 "Invalid IN value for declared type",
+			header:               CsvHeader{headers: []string{"header1"}, columnTypes: map[string]ColumnType{"header1": TypeInteger}},
+			rowFilterDefinitions: "header1 IN (1, x)",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 16, Offset: 15}, Definition: "header1 IN (1, x)", Msg: "'x' is not a valid integer"},
+		},
+		{
+			name:                 "Invalid quoted IN value on a later line",
+			header:               CsvHeader{headers: []string{"header1"}, columnTypes: map[string]ColumnType{"header1": TypeInteger}},
+			rowFilterDefinitions: "header1 > 0\nheader1 IN (1,  'x y', 2)",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 2, Column: 17, Offset: 28}, Definition: "header1 IN (1,  'x y', 2)", Msg: "'x y' is not a valid integer"},
+		},
+		{
+			name:                 "Invalid BETWEEN value for declared type",
+			header:               CsvHeader{headers: []string{"header1"}, columnTypes: map[string]ColumnType{"header1": TypeDecimal}},
+			rowFilterDefinitions: "header1 BETWEEN 1 AND x",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 23, Offset: 22}, Definition: "header1 BETWEEN 1 AND x", Msg: "'x' is not a valid decimal"},
+		},
+		{
+			name:                 "Text after IS NULL",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1 IS NULL x",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 17, Offset: 16}, Definition: "header1 IS NULL x", Msg: "unexpected 'x'"},
+		},
+		{
+			name:                 "Unknown collation",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "header1=1 COLLATE latin1",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 19, Offset: 18}, Definition: "header1=1 COLLATE latin1", Msg: "unknown collation 'latin1'"},
+		},
+		{
+			name:                 "Column differs in case without collation",
+			header:               CsvHeader{headers: []string{"header1"}},
+			rowFilterDefinitions: "HEADER1=1",
+			expected:             &UnknownColumnError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 1}, Column: "HEADER1"},
+		},
+		{
+			name:                 "Invalid value for declared type",
+			header:               CsvHeader{headers: []string{"header1"}, columnTypes: map[string]ColumnType{"header1": TypeInteger}},
+			rowFilterDefinitions: "header1>1.5",
+			expected:             &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 9, Offset: 8}, Definition: "header1>1.5", Msg: "'1.5' is not a valid integer"},
 		},
 	}
 
@@ -75,42 +531,126 @@ func TestApplyFilters(t *testing.T) {
 	tests := []struct {
 		name      string
 		row       []string
-		filters   []Filter
+		filters   FilterExpr
 		csvHeader CsvHeader
 		expected  bool
 	}{
 		{
 			name:      "All filters match",
 			row:       []string{"1", "2", "3"},
-			filters:   []Filter{{column: "header1", comparator: '=', value: "1"}, {column: "header2", comparator: '=', value: "2"}},
+			filters:   AndExpr{Filter{column: "header1", comparator: Equal, value: "1"}, Filter{column: "header2", comparator: Equal, value: "2"}},
 			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			expected:  true,
 		},
 		{
 			name:      "Some filters do not match",
 			row:       []string{"1", "2", "3"},
-			filters:   []Filter{{column: "header1", comparator: '=', value: "1"}, {column: "header2", comparator: '=', value: "3"}},
+			filters:   AndExpr{Filter{column: "header1", comparator: Equal, value: "1"}, Filter{column: "header2", comparator: Equal, value: "3"}},
 			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			expected:  false,
 		},
 		{
 			name:      "No filters",
 			row:       []string{"1", "2", "3"},
-			filters:   []Filter{},
+			filters:   AndExpr{},
 			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			expected:  true,
 		},
 		{
 			name:      "Filter on non-existent column",
 			row:       []string{"1", "2", "3"},
-			filters:   []Filter{{column: "header4", comparator: '=', value: "4"}},
+			filters:   AndExpr{Filter{column: "header4", comparator: Equal, value: "4"}},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  false,
+		},
+		{
+			name:      "OR with one match",
+			row:       []string{"1", "2", "3"},
+			filters:   OrExpr{Filter{column: "header1", comparator: Equal, value: "9"}, Filter{column: "header2", comparator: Equal, value: "2"}},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  true,
+		},
+		{
+			name:      "NOT",
+			row:       []string{"1", "2", "3"},
+			filters:   NotExpr{Expr: Filter{column: "header1", comparator: Equal, value: "1"}},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  false,
+		},
+		{
+			name:      "Column reference",
+			row:       []string{"1", "2", "3"},
+			filters:   Filter{column: "header3", comparator: Greater, valueColumn: "header2"},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  true,
+		},
+		{
+			name:      "Column reference BETWEEN",
+			row:       []string{"1", "2", "3"},
+			filters:   Filter{column: "header2", comparator: Between, valueColumn: "header1", upperColumn: "header3"},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  true,
+		},
+		{
+			name:      "Column reference CONTAINS",
+			row:       []string{"Acme Ltd", "ACME"},
+			filters:   Filter{column: "header1", comparator: Contains, valueColumn: "header2", collation: CollateNoCase},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2"}},
+			expected:  true,
+		},
+		{
+			name:      "Column reference missing from short row",
+			row:       []string{"1"},
+			filters:   Filter{column: "header1", comparator: NotEqual, valueColumn: "header3"},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  false,
+		},
+		{
+			name:      "Nil filter",
+			row:       []string{"1", "2", "3"},
+			filters:   nil,
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  true,
+		},
+		{
+			name:      "Filter on field missing from short row",
+			row:       []string{"1"},
+			filters:   AndExpr{Filter{column: "header3", comparator: Equal, value: ""}},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  false,
+		},
+		{
+			name:      "Missing field is null",
+			row:       []string{"1"},
+			filters:   Filter{column: "header3", comparator: IsNull, values: map[string]struct{}{"": {}}},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  true,
+		},
+		{
+			name:      "Missing field is not not null",
+			row:       []string{"1"},
+			filters:   Filter{column: "header3", comparator: IsNotNull, values: map[string]struct{}{"": {}}},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  false,
+		},
+		{
+			name:      "Missing field is not empty",
+			row:       []string{"1"},
+			filters:   Filter{column: "header3", comparator: IsEmpty},
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:  false,
+		},
+		{
+			name:      "Missing field is not non-empty",
+			row:       []string{"1"},
+			filters:   Filter{column: "header3", comparator: IsNotEmpty},
 			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			expected:  false,
 		},
 		{
 			name:      "Mixed filters with different comparators",
 			row:       []string{"1", "2", "3"},
-			filters:   []Filter{{column: "header1", comparator: '=', value: "1"}, {column: "header2", comparator: '>', value: "1"}, {column: "header3", comparator: '<', value: "4"}},
+			filters:   AndExpr{Filter{column: "header1", comparator: Equal, value: "1"}, Filter{column: "header2", comparator: Greater, value: "1"}, Filter{column: "header3", comparator: Less, value: "4"}},
 			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			expected:  true,
 		},
@@ -120,10 +660,28 @@ func TestApplyFilters(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			result := applyFilters(tt.row, tt.filters, tt.csvHeader)
 			assert.Equal(t, tt.expected, result)
+			bound := applyFilters(tt.row, bindFilters(tt.filters, tt.csvHeader), tt.csvHeader)
+			assert.Equal(t, tt.expected, bound)
 		})
 	}
 }
 
+func TestBindFilters(t *testing.T) {
+	csvHeader := CsvHeader{headers: []string{"header1", "header2", "header3"}}
+	filters := AndExpr{
+		Filter{column: "header2", comparator: Equal, value: "2"},
+		NotExpr{Expr: OrExpr{Filter{column: "header3", comparator: Between, valueColumn: "header1", upperColumn: "header2"}}},
+		Filter{column: "header4", comparator: Equal, value: "4"},
+	}
+	expected := AndExpr{
+		boundFilter{Filter: Filter{column: "header2", comparator: Equal, value: "2"}, index: 1, valueIndex: -1, upperIndex: -1},
+		NotExpr{Expr: OrExpr{boundFilter{Filter: Filter{column: "header3", comparator: Between, valueColumn: "header1", upperColumn: "header2"}, index: 2, valueIndex: 0, upperIndex: 1}}},
+		boundFilter{Filter: Filter{column: "header4", comparator: Equal, value: "4"}, index: -1, valueIndex: -1, upperIndex: -1},
+	}
+	assert.Equal(t, expected, bindFilters(filters, csvHeader))
+	assert.Nil(t, bindFilters(nil, csvHeader))
+}
+
 func TestApplyFilter(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -134,43 +692,283 @@ func TestApplyFilter(t *testing.T) {
 		{
 			name:     "Equal comparison - match",
 			value:    "5",
-			filter:   Filter{column: "header1", comparator: '=', value: "5"},
+			filter:   Filter{column: "header1", comparator: Equal, value: "5"},
 			expected: true,
 		},
 		{
 			name:     "Equal comparison - no match",
 			value:    "5",
-			filter:   Filter{column: "header1", comparator: '=', value: "6"},
+			filter:   Filter{column: "header1", comparator: Equal, value: "6"},
 			expected: false,
 		},
 		{
 			name:     "Greater than comparison - match",
 			value:    "7",
-			filter:   Filter{column: "header1", comparator: '>', value: "5"},
+			filter:   Filter{column: "header1", comparator: Greater, value: "5"},
 			expected: true,
 		},
 		{
 			name:     "Greater than comparison - no match",
 			value:    "5",
-			filter:   Filter{column: "header1", comparator: '>', value: "5"},
+			filter:   Filter{column: "header1", comparator: Greater, value: "5"},
 			expected: false,
 		},
 		{
 			name:     "Less than comparison - match",
 			value:    "3",
-			filter:   Filter{column: "header1", comparator: '<', value: "5"},
+			filter:   Filter{column: "header1", comparator: Less, value: "5"},
 			expected: true,
 		},
 		{
 			name:     "Less than comparison - no match",
 			value:    "5",
-			filter:   Filter{column: "header1", comparator: '<', value: "5"},
+			filter:   Filter{column: "header1", comparator: Less, value: "5"},
+			expected: false,
+		},
+		{
+			name:     "Greater than comparison - numeric",
+			value:    "100",
+			filter:   Filter{column: "header1", comparator: Greater, value: "9"},
+			expected: true,
+		},
+		{
+			name:     "Less than comparison - dates",
+			value:    "2024-01-31",
+			filter:   Filter{column: "header1", comparator: Less, value: "2024-02-01"},
+			expected: true,
+		},
+		{
+			name:     "Declared string column compares lexically",
+			value:    "100",
+			filter:   Filter{column: "header1", comparator: Greater, value: "9", columnType: TypeString},
+			expected: false,
+		},
+		{
+			name:     "Declared integer column with invalid value",
+			value:    "n/a",
+			filter:   Filter{column: "header1", comparator: Less, value: "9", columnType: TypeInteger},
+			expected: false,
+		},
+		{
+			name:     "Not equal comparison - match",
+			value:    "5",
+			filter:   Filter{column: "header1", comparator: NotEqual, value: "6"},
+			expected: true,
+		},
+		{
+			name:     "Not equal comparison - no match",
+			value:    "5.0",
+			filter:   Filter{column: "header1", comparator: NotEqual, value: "5"},
+			expected: false,
+		},
+		{
+			name:     "Greater or equal comparison - match",
+			value:    "5",
+			filter:   Filter{column: "header1", comparator: GreaterOrEqual, value: "5"},
+			expected: true,
+		},
+		{
+			name:     "Greater or equal comparison - no match",
+			value:    "4",
+			filter:   Filter{column: "header1", comparator: GreaterOrEqual, value: "5"},
+			expected: false,
+		},
+		{
+			name:     "Less or equal comparison - match",
+			value:    "5",
+			filter:   Filter{column: "header1", comparator: LessOrEqual, value: "5"},
+			expected: true,
+		},
+		{
+			name:     "Less or equal comparison - no match",
+			value:    "6",
+			filter:   Filter{column: "header1", comparator: LessOrEqual, value: "5"},
+			expected: false,
+		},
+		{
+			name:     "Contains - match",
+			value:    "Acme Ltd",
+			filter:   Filter{column: "header1", comparator: Contains, value: "Ltd", key: "Ltd"},
+			expected: true,
+		},
+		{
+			name:     "Contains - no match",
+			value:    "Acme Inc",
+			filter:   Filter{column: "header1", comparator: Contains, value: "Ltd", key: "Ltd"},
+			expected: false,
+		},
+		{
+			name:     "Starts with - match",
+			value:    "BR-123",
+			filter:   Filter{column: "header1", comparator: StartsWith, value: "BR-", key: "BR-"},
+			expected: true,
+		},
+		{
+			name:     "Starts with - no match",
+			value:    "PT-123",
+			filter:   Filter{column: "header1", comparator: StartsWith, value: "BR-", key: "BR-"},
+			expected: false,
+		},
+		{
+			name:     "Ends with - match",
+			value:    "123-X",
+			filter:   Filter{column: "header1", comparator: EndsWith, value: "-X", key: "-X"},
+			expected: true,
+		},
+		{
+			name:     "Ends with - no match",
+			value:    "123-Y",
+			filter:   Filter{column: "header1", comparator: EndsWith, value: "-X", key: "-X"},
+			expected: false,
+		},
+		{
+			name:     "Matches - match",
+			value:    "BR-123",
+			filter:   Filter{column: "header1", comparator: Matches, value: "^[A-Z]{2}-[0-9]+$", pattern: regexp.MustCompile("^[A-Z]{2}-[0-9]+$")},
+			expected: true,
+		},
+		{
+			name:     "Matches - no match",
+			value:    "BR-12a",
+			filter:   Filter{column: "header1", comparator: Matches, value: "^[A-Z]{2}-[0-9]+$", pattern: regexp.MustCompile("^[A-Z]{2}-[0-9]+$")},
+			expected: false,
+		},
+		{
+			name:     "In - match",
+			value:    "2.0",
+			filter:   Filter{column: "header1", comparator: In, values: map[string]struct{}{"1": {}, "2": {}}},
+			expected: true,
+		},
+		{
+			name:     "In - no match",
+			value:    "3",
+			filter:   Filter{column: "header1", comparator: In, values: map[string]struct{}{"1": {}, "2": {}}},
+			expected: false,
+		},
+		{
+			name:     "Not in - match",
+			value:    "3",
+			filter:   Filter{column: "header1", comparator: NotIn, values: map[string]struct{}{"1": {}, "2": {}}},
+			expected: true,
+		},
+		{
+			name:     "Not in - no match",
+			value:    "1",
+			filter:   Filter{column: "header1", comparator: NotIn, values: map[string]struct{}{"1": {}, "2": {}}},
+			expected: false,
+		},
+		{
+			name:     "Between - inclusive bounds",
+			value:    "100",
+			filter:   Filter{column: "header1", comparator: Between, value: "9", upper: "100"},
+			expected: true,
+		},
+		{
+			name:     "Between - no match",
+			value:    "101",
+			filter:   Filter{column: "header1", comparator: Between, value: "9", upper: "100"},
+			expected: false,
+		},
+		{
+			name:     "Not between - match",
+			value:    "2024-02-01",
+			filter:   Filter{column: "header1", comparator: NotBetween, value: "2024-01-01", upper: "2024-01-31"},
+			expected: true,
+		},
+		{
+			name:     "Not between - no match",
+			value:    "2024-01-15",
+			filter:   Filter{column: "header1", comparator: NotBetween, value: "2024-01-01", upper: "2024-01-31"},
+			expected: false,
+		},
+		{
+			name:     "Is null - null token",
+			value:    "N/A",
+			filter:   Filter{column: "header1", comparator: IsNull, values: map[string]struct{}{"N/A": {}}},
+			expected: true,
+		},
+		{
+			name:     "Is null - value",
+			value:    "5",
+			filter:   Filter{column: "header1", comparator: IsNull, values: map[string]struct{}{"N/A": {}}},
+			expected: false,
+		},
+		{
+			name:     "Is not null - null token",
+			value:    "N/A",
+			filter:   Filter{column: "header1", comparator: IsNotNull, values: map[string]struct{}{"N/A": {}}},
+			expected: false,
+		},
+		{
+			name:     "Is not null - empty string is not a token",
+			value:    "",
+			filter:   Filter{column: "header1", comparator: IsNotNull, values: map[string]struct{}{"N/A": {}}},
+			expected: true,
+		},
+		{
+			name:     "Is empty - match",
+			value:    "",
+			filter:   Filter{column: "header1", comparator: IsEmpty},
+			expected: true,
+		},
+		{
+			name:     "Is empty - no match",
+			value:    " ",
+			filter:   Filter{column: "header1", comparator: IsEmpty},
 			expected: false,
 		},
+		{
+			name:     "Is not empty - match",
+			value:    "x",
+			filter:   Filter{column: "header1", comparator: IsNotEmpty},
+			expected: true,
+		},
+		{
+			name:     "Equal comparison - NOCASE collation",
+			value:    "A\u00c7\u00c3O",
+			filter:   Filter{column: "header1", comparator: Equal, value: "ac\u0327a\u0303o", collation: CollateNoCase},
+			expected: true,
+		},
+		{
+			name:     "Contains - NFC collation",
+			value:    "Jo\u00e3o Silva",
+			filter:   Filter{column: "header1", comparator: Contains, value: "Joa\u0303o", key: "Jo\u00e3o", collation: CollateNFC},
+			expected: true,
+		},
+		{
+			name:     "Contains - binary collation",
+			value:    "Jo\u00e3o Silva",
+			filter:   Filter{column: "header1", comparator: Contains, value: "Joa\u0303o", key: "Joa\u0303o"},
+			expected: false,
+		},
+		{
+			name:     "Starts with - NOCASE collation",
+			value:    "br-123",
+			filter:   Filter{column: "header1", comparator: StartsWith, value: "BR-", key: "br-", collation: CollateNoCase},
+			expected: true,
+		},
+		{
+			name:     "Matches - NOCASE collation with expanding fold",
+			value:    "Stra\u00dfe",
+			filter:   Filter{column: "header1", comparator: Matches, value: "^STRA\u00dfE$", collation: CollateNoCase, pattern: regexp.MustCompile("(?i)^STRA\u00dfE$")},
+			expected: true,
+		},
+		{
+			name:     "Matches - NOCASE collation with ligature",
+			value:    "\ufb01le",
+			filter:   Filter{column: "header1", comparator: Matches, value: "^\ufb01le$", collation: CollateNoCase, pattern: regexp.MustCompile("(?i)^\ufb01le$")},
+			expected: true,
+		},
+		{
+			name:     "Numbers are not affected by collation",
+			value:    "100",
+			filter:   Filter{column: "header1", comparator: Greater, value: "9", collation: CollateNoCase},
+			expected: true,
+		},
 		{
 			name:     "Invalid comparator",
 			value:    "5",
-			filter:   Filter{column: "header1", comparator: '#', value: "5"},
+			filter:   Filter{column: "header1", comparator: Comparator("#"), value: "5"},
 			expected: false,
 		},
 	}
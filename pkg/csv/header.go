@@ -1,25 +1,145 @@
 package csv
 
-import "strings"
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 type CsvHeader struct {
 	headers            []string
 	selectedIndices    []int
 	numSelectedColumns int
+	columnTypes        map[string]ColumnType
+	nullTokens         []string
+	collation          Collation
+	// expressions are the computed columns of the selection, selected by
+	// indices starting at len(headers).
+	expressions []selectedExpr
+	// aliases are the output names of the renamed selected columns, keyed
+	// by position in selectedIndices.
+	aliases map[int]string
 }
 
 func (h *CsvHeader) Contains(s string) bool {
-	for _, col := range h.headers {
-		if col == s {
+	return h.indexOf(s, h.collation) != -1
+}
+
+// indexOf returns the index of the first header equal to name under
+// collation c, or -1 if there is none.
+func (h *CsvHeader) indexOf(name string, c Collation) int {
+	key := c.key(name)
+	for i, col := range h.headers {
+		if c.key(col) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// isNull reports whether value is one of the null tokens, which default
+// to the empty string.
+func (h *CsvHeader) isNull(value string) bool {
+	if h.nullTokens == nil {
+		return value == ""
+	}
+	for _, token := range h.nullTokens {
+		if h.collation.equal(value, token) {
 			return true
 		}
 	}
 	return false
 }
 
-func parseHeader(line string) CsvHeader {
-	headers := strings.Split(line, ",")
+// selectedHeaders returns the names of the selected columns.
+func (h *CsvHeader) selectedHeaders() []string {
+	if len(h.expressions) == 0 && len(h.aliases) == 0 {
+		return selectColumns(h.headers, h.selectedIndices)
+	}
+	names := make([]string, len(h.selectedIndices))
+	for i, idx := range h.selectedIndices {
+		switch alias, ok := h.aliases[i]; {
+		case ok:
+			names[i] = alias
+		case idx < len(h.headers):
+			names[i] = h.headers[idx]
+		default:
+			names[i] = h.expressions[idx-len(h.headers)].name
+		}
+	}
+	return names
+}
+
+// selectRow returns the selected fields of row, evaluating the computed
+// columns. Fields missing from a short row are null.
+func (h *CsvHeader) selectRow(row []string) []string {
+	if len(h.expressions) == 0 {
+		return selectColumns(row, h.selectedIndices)
+	}
+	var selected []string
+	for _, idx := range h.selectedIndices {
+		var field string
+		switch {
+		case idx >= len(h.headers):
+			field, _ = h.expressions[idx-len(h.headers)].expr.eval(row, h)
+		case idx < len(row):
+			field = row[idx]
+		}
+		selected = append(selected, field)
+	}
+	return selected
+}
+
+func parseHeader(csvData string) (CsvHeader, error) {
+	return readHeader(newRecordReader(strings.NewReader(csvData), Dialect{}))
+}
+
+// readHeader consumes the first record of reader. An empty input yields an
+// empty header.
+func readHeader(reader *recordReader) (CsvHeader, error) {
+	headers, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return CsvHeader{}, err
+	}
 	return CsvHeader{
 		headers: headers,
+	}, nil
+}
+
+// positionalHeader names the columns of headerless input after names and
+// the columns past them c1, c2, ... by position. It peeks at the first
+// record of reader to count the columns; with lenient, at the first
+// well-formed one, leaving the malformed records before it to be rejected.
+func positionalHeader(reader *recordReader, names []string, lenient bool) (CsvHeader, error) {
+	record, err := reader.Peek(lenient)
+	if err != nil && err != io.EOF {
+		return CsvHeader{}, err
+	}
+	headers := append([]string(nil), names...)
+	for i := len(headers); i < len(record); i++ {
+		headers = append(headers, "c"+strconv.Itoa(i+1))
+	}
+	return CsvHeader{
+		headers: headers,
+	}, nil
+}
+
+// setColumnTypes declares how filters compare the named columns. Columns
+// without a declared type use TypeAuto.
+func (h *CsvHeader) setColumnTypes(types map[string]ColumnType) error {
+	columns := make([]string, 0, len(types))
+	for col := range types {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	h.columnTypes = make(map[string]ColumnType, len(types))
+	for _, col := range columns {
+		i := h.indexOf(col, h.collation)
+		if i == -1 {
+			return &UnknownColumnError{Position: Position{Source: "ColumnTypes"}, Column: col}
+		}
+		h.columnTypes[h.headers[i]] = types[col]
 	}
+	return nil
 }
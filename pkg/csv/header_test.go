@@ -2,6 +2,7 @@ package csv
 
 import (
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -39,12 +40,109 @@ func TestParseCsvHeader(t *testing.T) {
 				headers: []string{"header1"},
 			},
 		},
+		{
+			name: "Quoted headers",
+			line: "\"last, first\",\"say \"\"hi\"\"\",\"multi\nline\"\n1,2,3",
+			expected: CsvHeader{
+				headers: []string{"last, first", "say \"hi\"", "multi\nline"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseHeader(tt.line)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestSelectedHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		csvHeader CsvHeader
+		expected  []string
+	}{
+		{
+			name:      "Original names",
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}, selectedIndices: []int{2, 0}},
+			expected:  []string{"header3", "header1"},
+		},
+		{
+			name:      "Aliases",
+			csvHeader: CsvHeader{headers: []string{"header1", "header2", "header3"}, selectedIndices: []int{2, 0, 2}, aliases: map[int]string{2: "copy"}},
+			expected:  []string{"header3", "header1", "copy"},
+		},
+		{
+			name: "Computed columns",
+			csvHeader: CsvHeader{
+				headers:         []string{"header1", "header2"},
+				selectedIndices: []int{2, 0},
+				expressions:     []selectedExpr{{name: "total", expr: literal{value: "1"}}},
+				aliases:         map[int]string{1: "id"},
+			},
+			expected: []string{"total", "id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.csvHeader.selectedHeaders())
+		})
+	}
+}
+
+func TestPositionalHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		names    []string
+		lenient  bool
+		expected CsvHeader
+		next     []string
+	}{
+		{
+			name:     "Positional names",
+			input:    "1,2,3\n4,5,6\n",
+			expected: CsvHeader{headers: []string{"c1", "c2", "c3"}},
+			next:     []string{"1", "2", "3"},
+		},
+		{
+			name:     "User-supplied names",
+			input:    "1,2\n",
+			names:    []string{"id", "amount"},
+			expected: CsvHeader{headers: []string{"id", "amount"}},
+			next:     []string{"1", "2"},
+		},
+		{
+			name:     "Fewer names than columns",
+			input:    "1,2,3\n",
+			names:    []string{"id"},
+			expected: CsvHeader{headers: []string{"id", "c2", "c3"}},
+			next:     []string{"1", "2", "3"},
+		},
+		{
+			name:     "Empty input",
+			input:    "",
+			expected: CsvHeader{headers: nil},
+		},
+		{
+			name:     "Malformed first record when lenient",
+			input:    "1\"x,2\n3,4,5\n",
+			lenient:  true,
+			expected: CsvHeader{headers: []string{"c1", "c2", "c3"}},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := parseHeader(tt.line)
+			reader := newRecordReader(strings.NewReader(tt.input), Dialect{})
+			result, err := positionalHeader(reader, tt.names, tt.lenient)
+			assert.Nil(t, err)
 			assert.Equal(t, tt.expected, result)
+			next, _ := reader.Read()
+			assert.Equal(t, tt.next, next)
 		})
 	}
 }
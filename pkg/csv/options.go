@@ -0,0 +1,96 @@
+package csv
+
+import "io"
+
+// Options configures how Process selects and filters records.
+type Options struct {
+	// SelectedColumns is a comma-separated list of the columns to output,
+	// in order. Each entry is a header name, a 1-based position, a range
+	// such as "col2..col9", a glob such as "amount_*", a regular expression
+	// such as "/^amount_/", an exclusion such as "-col5" or an expression
+	// computing a new column. Names and expressions can be given an output
+	// name with AS, as in "col1 AS customer_id" or "price * qty AS total".
+	// Expressions support arithmetic, "||" concatenation and the functions
+	// upper, lower, trim, substr, round and coalesce. An empty string
+	// selects every column.
+	SelectedColumns string
+	// RowFilterDefinitions holds one filter expression per line, combining
+	// comparisons with AND, OR, NOT and parentheses; a row is written only
+	// if it satisfies every line. See ParseFilters for the grammar.
+	RowFilterDefinitions string
+	// ColumnTypes declares how filters compare the values of the named
+	// columns. Columns not listed use TypeAuto.
+	ColumnTypes map[string]ColumnType
+	// NullTokens are the values matched by "IS NULL" filters and treated
+	// as NULL by computed columns, such as "", "NULL" or "N/A". Fields
+	// missing from short rows are always null. A nil slice treats only the
+	// empty string as null.
+	NullTokens []string
+	// Collation controls how selected and filtered column names are matched
+	// against the header and how filters compare string values. Filters can
+	// override it with a trailing COLLATE clause.
+	Collation Collation
+	// Source names the input in errors, such as its file path.
+	Source string
+	// InputDialect describes how the input records are delimited and
+	// quoted. The zero value is RFC 4180.
+	InputDialect Dialect
+	// DetectDialect guesses the input dialect and encoding from the first
+	// records, as Sniff does, instead of using InputDialect. Input that is
+	// not UTF-8 is decoded.
+	DetectDialect bool
+	// DetectHeader, with DetectDialect, reads input whose first record
+	// does not look like a header to Sniff as if NoHeader were set.
+	DetectHeader bool
+	// NoHeader declares that the first record is data rather than a
+	// header. The columns are then named after ColumnNames or, past its
+	// end, c1, c2, ... by position, and the output header uses these names.
+	NoHeader bool
+	// ColumnNames names the columns of input without a header.
+	ColumnNames []string
+	// OutputDialect describes how the output records are delimited and
+	// quoted. The zero value is RFC 4180.
+	OutputDialect Dialect
+	// ShortRows and LongRows control the rows with fewer or more fields
+	// than the header. The zero value, RaggedAllow, processes them as
+	// they are.
+	ShortRows RaggedPolicy
+	LongRows  RaggedPolicy
+	// Lenient rejects the records that fail to parse, with their text as
+	// the only field, and carries on with the next line instead of
+	// failing. An unterminated quoted field still runs to the end of the
+	// input.
+	Lenient bool
+	// Rejects receives the rows quarantined by RaggedQuarantine and the
+	// records rejected by Lenient, each prefixed with the line on which it
+	// starts and the reason, in the output dialect. A nil Rejects discards
+	// them.
+	Rejects io.Writer
+	// OnReject, if not nil, is called with each rejected row as it is
+	// written to Rejects. An error aborts the run.
+	OnReject func(line int, reason string, fields []string) error
+	// MaxRejects, if positive, aborts the run with ErrTooManyRejects once
+	// more than MaxRejects rows have been rejected.
+	MaxRejects int
+	// Stats, if not nil, receives the row counts of Process, even if it
+	// fails. Prepare ignores it, since Query.Run returns the counts.
+	Stats *Stats
+}
+
+// Stats counts the rows seen by Process or a run of a Query.
+type Stats struct {
+	// Rows is the number of records read, excluding the header and the
+	// malformed records.
+	Rows int
+	// Written is the number of rows written to the output.
+	Written int
+	// ShortRows and LongRows are the numbers of rows with fewer or more
+	// fields than the header, whatever the policy applied to them.
+	ShortRows int
+	LongRows  int
+	// Malformed is the number of records that failed to parse and were
+	// rejected by Options.Lenient.
+	Malformed int
+	// Rejected is the number of rows written to Options.Rejects.
+	Rejected int
+}
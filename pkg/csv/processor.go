@@ -1,57 +1,144 @@
 package csv
 
 import (
-	"encoding/csv"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
-func processCsvData(csvData string, csvHeader CsvHeader, filters []Filter) {
-	lines := strings.Split(csvData, "\n")
-
-	fmt.Println(strings.Join(selectColumns(csvHeader.headers, csvHeader.selectedIndices), ","))
-
-	for _, line := range lines[1:] {
-		row := strings.Split(line, ",")
-		if applyFilters(row, filters, csvHeader) {
-			fmt.Println(strings.Join(selectColumns(row, csvHeader.selectedIndices), ","))
+// writeCsvData writes the selected header columns and then streams the
+// remaining records of reader, one at a time, through the ragged row
+// policies and the filters, counting them in stats. Malformed records are
+// handed to the rejects of ragged. A nil ragged allows ragged rows and
+// fails on malformed records, and a nil stats discards the counts.
+func writeCsvData(ctx context.Context, reader *recordReader, writer *recordWriter, csvHeader CsvHeader, filters FilterExpr, ragged *raggedRows, stats *Stats) error {
+	if stats == nil {
+		stats = &Stats{}
+	}
+	var rejects *rejectWriter
+	if ragged != nil {
+		rejects = ragged.rejects
+	}
+	if err := writer.Write(csvHeader.selectedHeaders()); err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			if err = rejects.malformed(parseErr, reader.recordLine, reader.rawRecord()); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		stats.Rows++
+		if row, err = ragged.check(row, reader.recordPosition()); err != nil {
+			return err
+		}
+		if row != nil && applyFilters(row, filters, csvHeader) {
+			if err := writer.Write(csvHeader.selectRow(row)); err != nil {
+				return err
+			}
+			stats.Written++
 		}
 	}
 }
 
-func ProcessCsv(csvData string, selectedColumns string, rowFilterDefinitions string) error {
-	csvHeader := parseHeader(strings.Split(csvData, "\n")[0])
-	err := parseSelectedColumns(selectedColumns, &csvHeader)
+// Process reads CSV records from r and writes the selected columns of the
+// rows that pass the filters in opts to w. The first record of r is the
+// header unless opts.NoHeader is set. The selection, column types and
+// filters are compiled against the header first, so an unknown column or
+// a malformed definition fails before anything is written. Processing
+// stops early if ctx is cancelled. To run the same selection and filters
+// against many inputs, Prepare a Query instead.
+func Process(ctx context.Context, r io.Reader, w io.Writer, opts Options) error {
+	reader, noHeader, err := openReader(r, opts)
+	if err != nil {
+		return err
+	}
+	var csvHeader CsvHeader
+	if noHeader {
+		csvHeader, err = positionalHeader(reader, opts.ColumnNames, opts.Lenient)
+	} else {
+		csvHeader, err = readHeader(reader)
+	}
+	if err != nil {
+		return err
+	}
+	q, err := compileQuery(csvHeader, opts)
 	if err != nil {
 		return err
 	}
-	filters, err := ParseFilters(rowFilterDefinitions, csvHeader)
-	processCsvData(csvData, csvHeader, filters)
-	return nil
+	stats, err := q.run(ctx, reader, w)
+	if opts.Stats != nil {
+		*opts.Stats = stats
+	}
+	return err
 }
 
-func ProcessCsvFile(csvFilePath string, selectedColumns string, rowFilterDefinitions string) error {
+// openReader validates the dialects of opts and returns a reader of r in
+// the input dialect, sniffed if opts.DetectDialect is set. It reports
+// whether the input has no header, which is guessed too if
+// opts.DetectHeader is set.
+func openReader(r io.Reader, opts Options) (*recordReader, bool, error) {
+	inputDialect, noHeader := opts.InputDialect, opts.NoHeader
+	if opts.DetectDialect {
+		var sniffed SniffResult
+		var err error
+		if sniffed, r, err = sniff(r); err != nil {
+			return nil, false, err
+		}
+		inputDialect = sniffed.Dialect
+		noHeader = noHeader || opts.DetectHeader && !sniffed.HasHeader
+	}
+	if err := inputDialect.validate(); err != nil {
+		return nil, false, err
+	}
+	if err := opts.OutputDialect.validate(); err != nil {
+		return nil, false, err
+	}
+	reader := newRecordReader(r, inputDialect)
+	reader.source = opts.Source
+	reader.keepRaw = opts.Lenient
+	return reader, noHeader, nil
+}
+
+func ProcessCsv(csvData string, selectedColumns string, rowFilterDefinitions string) error {
+	return Process(context.Background(), strings.NewReader(csvData), os.Stdout, Options{
+		SelectedColumns:      selectedColumns,
+		RowFilterDefinitions: rowFilterDefinitions,
+	})
+}
+
+// ProcessFile is like Process but reads the CSV records from the file at
+// csvFilePath, which names the input in errors unless opts.Source is set.
+func ProcessFile(ctx context.Context, csvFilePath string, w io.Writer, opts Options) error {
 	file, err := os.Open(csvFilePath)
 	if err != nil {
-		return fmt.Errorf("Failed to open file %s:, error:%v\n", csvFilePath, err)
+		return fmt.Errorf("Failed to open file %s: %w", csvFilePath, err)
 	}
 	defer func() { _ = file.Close() }()
 
-	reader := csv.NewReader(file)
-	lines, err := reader.ReadAll()
-	if err != nil {
-		return fmt.Errorf("Failed to read file %s: error %v\n", csvFilePath, err)
+	if opts.Source == "" {
+		opts.Source = csvFilePath
 	}
-
-	csvData := strings.Join(flatten(lines), "\n")
-	return ProcessCsv(csvData, selectedColumns, rowFilterDefinitions)
+	return Process(ctx, file, w, opts)
 }
 
-func flatten(records [][]string) []string {
-	var flat []string
-	for _, record := range records {
-		flat = append(flat, strings.Join(record, ","))
-	}
-	return flat
+func ProcessCsvFile(csvFilePath string, selectedColumns string, rowFilterDefinitions string) error {
+	return ProcessFile(context.Background(), csvFilePath, os.Stdout, Options{
+		SelectedColumns:      selectedColumns,
+		RowFilterDefinitions: rowFilterDefinitions,
+	})
 }
@@ -1,139 +1,17 @@
 package csv
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
-func TestProcessCsvData(t *testing.T) {
-	tests := []struct {
-		name      string
-		csvData   string
-		csvHeader CsvHeader
-		filters   []Filter
-		expected  string
-	}{
-		{
-			name:      "No filters, all columns",
-			csvData:   "header1,header2,header3\n1,2,3\n4,5,6",
-			csvHeader: CsvHeader{},
-			filters:   []Filter{},
-			expected:  "header1,header2,header3\n1,2,3\n4,5,6\n",
-		},
-		{
-			name:      "With filters, all columns",
-			csvData:   "header1,header2,header3\n1,2,3\n4,5,6",
-			csvHeader: CsvHeader{},
-			filters:   []Filter{{column: "header1", comparator: '>', value: "1"}},
-			expected:  "header1,header2,header3\n4,5,6\n",
-		},
-		{
-			name:      "With filters, selected columns",
-			csvData:   "header1,header2,header3\n1,2,3\n4,5,6",
-			csvHeader: CsvHeader{},
-			filters:   []Filter{{column: "header1", comparator: '>', value: "1"}},
-			expected:  "header1,header2,header3\n4,5,6\n",
-		},
-		{
-			name:      "With filters, different columns",
-			csvData:   "header1,header2,header3\n1,2,3\n4,5,6",
-			csvHeader: CsvHeader{},
-			filters:   []Filter{{column: "header2", comparator: '=', value: "5"}},
-			expected:  "header1,header2,header3\n4,5,6\n",
-		},
-		{
-			name:      "Empty CSV data",
-			csvData:   "",
-			csvHeader: CsvHeader{},
-			filters:   []Filter{},
-			expected:  "\n",
-		},
-		{
-			name:      "No match filters",
-			csvData:   "header1,header2,header3\n1,2,3\n4,5,6",
-			csvHeader: CsvHeader{},
-			filters:   []Filter{{column: "header1", comparator: '=', value: "10"}},
-			expected:  "header1,header2,header3\n",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			processCsvData(tt.csvData, tt.csvHeader, tt.filters)
-		})
-	}
-}
-
-func TestFlatten(t *testing.T) {
-	tests := []struct {
-		name     string
-		records  [][]string
-		expected []string
-	}{
-		{
-			name: "Multiple records",
-			records: [][]string{
-				{"header1", "header2", "header3"},
-				{"1", "2", "3"},
-				{"4", "5", "6"},
-			},
-			expected: []string{
-				"header1,header2,header3",
-				"1,2,3",
-				"4,5,6",
-			},
-		},
-		{
-			name: "Single record",
-			records: [][]string{
-				{"header1", "header2", "header3"},
-			},
-			expected: []string{
-				"header1,header2,header3",
-			},
-		},
-		{
-			name:     "Empty records",
-			records:  [][]string{},
-			expected: nil,
-		},
-		{
-			name: "Empty strings in records",
-			records: [][]string{
-				{"header1", "header2", "header3"},
-				{"", "", ""},
-				{"4", "5", "6"},
-			},
-			expected: []string{
-				"header1,header2,header3",
-				",,",
-				"4,5,6",
-			},
-		},
-		{
-			name: "Records with spaces",
-			records: [][]string{
-				{" header1 ", " header2 ", " header3 "},
-				{" 1 ", " 2 ", " 3 "},
-			},
-			expected: []string{
-				" header1 , header2 , header3 ",
-				" 1 , 2 , 3 ",
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := flatten(tt.records)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 func captureOutput(f func()) string {
 	r, w, _ := os.Pipe()
 	stdout := os.Stdout
@@ -212,6 +90,20 @@ func TestProcessCsv(t *testing.T) {
 			rowFilterDefinitions: "header1=10",
 			expected:             "header1,header2,header3\n",
 		},
+		{
+			name:                 "Quoted fields round trip",
+			csvData:              "id,name,note\n1,\"Doe, John\",\"said \"\"hi\"\"\"\n2,Jane,\"line1\nline2\"\n",
+			selectedColumns:      "name,note",
+			rowFilterDefinitions: "id>0",
+			expected:             "name,note\n\"Doe, John\",\"said \"\"hi\"\"\"\nJane,\"line1\nline2\"\n",
+		},
+		{
+			name:                 "Filter on quoted field",
+			csvData:              "id,name\n1,\"Doe, John\"\n2,Jane\n",
+			selectedColumns:      "id",
+			rowFilterDefinitions: "name=Doe, John",
+			expected:             "id\n1\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -240,7 +132,21 @@ func TestProcessCsvError(t *testing.T) {
 			csvData:              "header1,header2,header3\n1,2,3\n4,5,6",
 			selectedColumns:      "header0",
 			rowFilterDefinitions: "",
-			expected:             "Header 'header0' not found in CSV file/string",
+			expected:             "SelectedColumns:1:1: Header 'header0' not found in CSV file/string",
+		},
+		{
+			name:                 "malformed filter",
+			csvData:              "header1,header2,header3\n1,2,3\n4,5,6",
+			selectedColumns:      "",
+			rowFilterDefinitions: "header1>1\nheader2",
+			expected:             "RowFilterDefinitions:2:8: Invalid filter 'header2': missing comparison operator",
+		},
+		{
+			name:                 "unknown filtered column",
+			csvData:              "header1,header2,header3\n1,2,3\n4,5,6",
+			selectedColumns:      "header1",
+			rowFilterDefinitions: "header4=1",
+			expected:             "RowFilterDefinitions:1:1: Header 'header4' not found in CSV file/string",
 		},
 	}
 
@@ -307,6 +213,13 @@ func TestProcessCsvFile(t *testing.T) {
 			rowFilterDefinitions: "header1=10",
 			expectedOutput:       "header1,header2,header3\n",
 		},
+		{
+			name:                 "Quoted fields round trip",
+			fileContent:          "id,name,note\n1,\"Doe, John\",\"said \"\"hi\"\"\"\n2,Jane,\"line1\nline2\"\n",
+			selectedColumns:      "name,note",
+			rowFilterDefinitions: "id>0",
+			expectedOutput:       "name,note\n\"Doe, John\",\"said \"\"hi\"\"\"\nJane,\"line1\nline2\"\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -334,3 +247,418 @@ func TestProcessCsvFile(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/bad.csv"
+	if err := os.WriteFile(path, []byte("id,name\n1,a\n2,b\"c\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err := ProcessFile(context.Background(), path, &buf, Options{})
+	var parseErr *ParseError
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, Position{Source: path, Line: 3, Column: 4, Offset: 15, Field: 2}, parseErr.Position)
+	assert.EqualError(t, err, path+": parse error on line 3, column 4: bare \" in non-quoted field")
+
+	err = ProcessFile(context.Background(), path, &buf, Options{Source: "orders"})
+	assert.EqualError(t, err, "orders: parse error on line 3, column 4: bare \" in non-quoted field")
+
+	err = ProcessFile(context.Background(), path, &buf, Options{SelectedColumns: "id, amount"})
+	var unknownColumnErr *UnknownColumnError
+	assert.True(t, errors.As(err, &unknownColumnErr))
+	assert.Equal(t, Position{Source: "SelectedColumns", Line: 1, Column: 5, Offset: 4, Field: 2}, unknownColumnErr.Position)
+
+	missing := dir + "/missing.csv"
+	err = ProcessFile(context.Background(), missing, &buf, Options{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.EqualError(t, err, "Failed to open file "+missing+": open "+missing+": no such file or directory")
+}
+
+type rowGenerator struct {
+	rows    int
+	pending []byte
+}
+
+func (g *rowGenerator) Read(p []byte) (int, error) {
+	if len(g.pending) == 0 {
+		if g.rows == 0 {
+			return 0, io.EOF
+		}
+		g.rows--
+		g.pending = []byte(fmt.Sprintf("%d,\"row, %d\",x\n", g.rows%10, g.rows))
+	}
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+type lineCounter struct {
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			c.lines++
+		}
+	}
+	return len(p), nil
+}
+
+func TestWriteCsvDataStreams(t *testing.T) {
+	csvHeader := CsvHeader{headers: []string{"id", "name", "x"}, selectedIndices: []int{0, 1}}
+	filters := AndExpr{Filter{column: "id", comparator: Equal, value: "0"}}
+	counter := &lineCounter{}
+	writer := newRecordWriter(counter, Dialect{})
+
+	err := writeCsvData(context.Background(), newRecordReader(&rowGenerator{rows: 100000}, Dialect{}), writer, csvHeader, filters, nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Flush())
+	assert.Equal(t, 10001, counter.lines)
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name     string
+		csvData  string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "No filters, all columns",
+			csvData:  "header1,header2,header3\n1,2,3\n4,5,6",
+			opts:     Options{},
+			expected: "header1,header2,header3\n1,2,3\n4,5,6\n",
+		},
+		{
+			name:     "With filters, all columns",
+			csvData:  "header1,header2,header3\n1,2,3\n4,5,6",
+			opts:     Options{RowFilterDefinitions: "header1>1"},
+			expected: "header1,header2,header3\n4,5,6\n",
+		},
+		{
+			name:     "With filters, different columns",
+			csvData:  "header1,header2,header3\n1,2,3\n4,5,6",
+			opts:     Options{RowFilterDefinitions: "header2=5"},
+			expected: "header1,header2,header3\n4,5,6\n",
+		},
+		{
+			name:     "Empty CSV data",
+			csvData:  "",
+			opts:     Options{},
+			expected: "\n",
+		},
+		{
+			name:     "Single empty field survives",
+			csvData:  "h\n\"\"\nx\n",
+			opts:     Options{},
+			expected: "h\n\"\"\nx\n",
+		},
+		{
+			name:     "Selected empty field",
+			csvData:  "id,note\n1,\n2,b\n",
+			opts:     Options{SelectedColumns: "note"},
+			expected: "note\n\"\"\nb\n",
+		},
+		{
+			name:     "Headerless null rows",
+			csvData:  "1,\n2,b\n",
+			opts:     Options{NoHeader: true, SelectedColumns: "c2", RowFilterDefinitions: "c2 IS NULL"},
+			expected: "c2\n\"\"\n",
+		},
+		{
+			name:     "No match filters",
+			csvData:  "header1,header2,header3\n1,2,3\n4,5,6",
+			opts:     Options{RowFilterDefinitions: "header1=10"},
+			expected: "header1,header2,header3\n",
+		},
+		{
+			name:     "With filters, selected columns",
+			csvData:  "header1,header2,header3\n1,2,3\n4,5,6",
+			opts:     Options{SelectedColumns: "header3,header1", RowFilterDefinitions: "header1>1"},
+			expected: "header3,header1\n6,4\n",
+		},
+		{
+			name:     "Numeric filter",
+			csvData:  "item,price\na,9\nb,100\nc,25.50\n",
+			opts:     Options{RowFilterDefinitions: "price>10"},
+			expected: "item,price\nb,100\nc,25.50\n",
+		},
+		{
+			name:     "Numeric filter skips values that are not numbers",
+			csvData:  "item,price\na,N/A\nb,150\nc,\nd,50\n",
+			opts:     Options{SelectedColumns: "item", RowFilterDefinitions: "price>100 OR price<100"},
+			expected: "item\nb\nd\n",
+		},
+		{
+			name:     "Date filter skips values that are not dates",
+			csvData:  "id,due\n1,2024-03-01\n2,soon\n3,01/02/2024\n",
+			opts:     Options{SelectedColumns: "id", RowFilterDefinitions: "due >= 2024-02-01"},
+			expected: "id\n1\n3\n",
+		},
+		{
+			name:     "Boolean expression",
+			csvData:  "id,status\n1,open\n2,closed\n3,pending\n",
+			opts:     Options{RowFilterDefinitions: "status=open OR status=pending AND NOT id=3"},
+			expected: "id,status\n1,open\n",
+		},
+		{
+			name:     "Pattern filters",
+			csvData:  "code,name\nBR-1,Acme Ltd\nPT-2,Foo Ltd\nBR-3,Bar Inc\n",
+			opts:     Options{RowFilterDefinitions: "code STARTS WITH BR- AND name CONTAINS Ltd OR code MATCHES ^PT-[0-9]$"},
+			expected: "code,name\nBR-1,Acme Ltd\nPT-2,Foo Ltd\n",
+		},
+		{
+			name:     "IN and BETWEEN",
+			csvData:  "account,amount\n1001,5\n1002,50\n1003,500\n",
+			opts:     Options{RowFilterDefinitions: "account IN (1001, 1002, 1003) AND amount BETWEEN 10 AND 1000"},
+			expected: "account,amount\n1002,50\n1003,500\n",
+		},
+		{
+			name:     "NULL tokens and missing fields",
+			csvData:  "id,amount\n1,N/A\n2,\n3\n4,5\n",
+			opts:     Options{SelectedColumns: "id", RowFilterDefinitions: "amount IS NULL", NullTokens: []string{"N/A"}},
+			expected: "id\n1\n3\n",
+		},
+		{
+			name:     "Empty but not missing fields",
+			csvData:  "id,amount\n1,N/A\n2,\n3\n4,5\n",
+			opts:     Options{SelectedColumns: "id", RowFilterDefinitions: "amount IS EMPTY"},
+			expected: "id\n2\n",
+		},
+		{
+			name:     "NOCASE collation",
+			csvData:  "Cidade,Situa\u00e7\u00e3o\nS\u00e3o Paulo,ABERTO\nBel\u00e9m,fechado\n",
+			opts:     Options{SelectedColumns: "cidade", RowFilterDefinitions: "SITUA\u00c7\u00c3O = aberto", Collation: CollateNoCase},
+			expected: "Cidade\nS\u00e3o Paulo\n",
+		},
+		{
+			name:     "Declared column types",
+			csvData:  "item,code\na,9\nb,100\n",
+			opts:     Options{RowFilterDefinitions: "code>5", ColumnTypes: map[string]ColumnType{"code": TypeString}},
+			expected: "item,code\na,9\n",
+		},
+		{
+			name:     "Column to column comparison",
+			csvData:  "id,ordered,shipped\n1,2024-01-10,2024-01-09\n2,2024-01-10,2024-01-12\n3,09/01/2024,10/01/2024\n",
+			opts:     Options{SelectedColumns: "id", RowFilterDefinitions: "shipped > `ordered`", ColumnTypes: map[string]ColumnType{"shipped": TypeTimestamp}},
+			expected: "id\n2\n3\n",
+		},
+		{
+			name:     "Renamed columns",
+			csvData:  "col1,col2\n7,a\n8,b\n",
+			opts:     Options{SelectedColumns: "col1 AS customer_id,col2 AS `customer name`", RowFilterDefinitions: "col1=8"},
+			expected: "customer_id,customer name\n8,b\n",
+		},
+		{
+			name:     "Column selectors",
+			csvData:  "id,amount_1,amount_2,note\n1,10,20,x\n2,30,40,y\n",
+			opts:     Options{SelectedColumns: "note,id..amount_2,-2", RowFilterDefinitions: "id=2"},
+			expected: "note,id,amount_2\ny,2,40\n",
+		},
+		{
+			name:     "Computed columns",
+			csvData:  "item,price,qty\nwidget,2.50,4\ngadget,10,\n",
+			opts:     Options{SelectedColumns: "upper(item) AS item, price*qty AS total, round(price * 1.1, 2)", RowFilterDefinitions: "price > 1"},
+			expected: "item,total,\"round(price * 1.1, 2)\"\nWIDGET,10,2.75\nGADGET,,11.00\n",
+		},
+		{
+			name:     "Semicolon input, TSV output",
+			csvData:  "nome;valor\n\"Feij\u00e3o; preto\";2,75\nArroz;1,50\n",
+			opts:     Options{RowFilterDefinitions: "nome STARTS WITH Feij", InputDialect: Dialect{Delimiter: ';'}, OutputDialect: TSV},
+			expected: "nome\tvalor\nFeij\u00e3o; preto\t2,75\n",
+		},
+		{
+			name:     "Detected dialect",
+			csvData:  "id|name\r\n1|'a|b'\r\n2|c\r\n",
+			opts:     Options{SelectedColumns: "name", DetectDialect: true, OutputDialect: Dialect{LineTerminator: "\r\n"}},
+			expected: "name\r\na|b\r\nc\r\n",
+		},
+		{
+			name:     "Headerless input",
+			csvData:  "1,a,10\n2,b,20\n",
+			opts:     Options{SelectedColumns: "c3,c1", RowFilterDefinitions: "c2=a", NoHeader: true},
+			expected: "c3,c1\n10,1\n",
+		},
+		{
+			name:     "Headerless input with column names",
+			csvData:  "1,a,10\n2,b,20\n",
+			opts:     Options{SelectedColumns: "name,amount", RowFilterDefinitions: "id>1", NoHeader: true, ColumnNames: []string{"id", "name", "amount"}},
+			expected: "name,amount\nb,20\n",
+		},
+		{
+			name:     "Detected headerless input",
+			csvData:  "1;2,50;x\n2;3,75;y\n",
+			opts:     Options{SelectedColumns: "c2", DetectDialect: true, DetectHeader: true},
+			expected: "c2\n\"2,50\"\n\"3,75\"\n",
+		},
+		{
+			name:     "Detected dialect of equal-width codes",
+			csvData:  "col1,col2,col3\nl1c1,l1c2,l1c3\nl2c1,l2c2,l2c3\n",
+			opts:     Options{SelectedColumns: "col1,col3", DetectDialect: true},
+			expected: "col1,col3\nl1c1,l1c3\nl2c1,l2c3\n",
+		},
+		{
+			name:     "Detected dialect keeps the header",
+			csvData:  "1;2,50;x\n2;3,75;y\n",
+			opts:     Options{SelectedColumns: "1", DetectDialect: true},
+			expected: "1\n2\n",
+		},
+		{
+			name:     "Empty CSV data",
+			csvData:  "",
+			opts:     Options{},
+			expected: "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Process(context.Background(), strings.NewReader(tt.csvData), &buf, tt.opts)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestProcessError(t *testing.T) {
+	var buf bytes.Buffer
+	err := Process(context.Background(), strings.NewReader("header1\n1\n"), &buf, Options{SelectedColumns: "header0"})
+	assert.Equal(t, &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Column: "header0"}, err)
+	assert.Equal(t, "", buf.String())
+}
+
+func TestProcessInvalidFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters string
+		err     string
+	}{
+		{name: "Missing operator", filters: "header1", err: "RowFilterDefinitions:1:8: Invalid filter 'header1': missing comparison operator"},
+		{name: "BETWEEN without AND", filters: "header1 BETWEEN 1", err: "RowFilterDefinitions:1:18: Invalid filter 'header1 BETWEEN 1': missing AND in BETWEEN"},
+		{name: "Unknown column", filters: "header1 > 0\nheader2 = x", err: "RowFilterDefinitions:2:1: Header 'header2' not found in CSV file/string"},
+		{name: "Unbalanced parenthesis", filters: "(header1 = 1))", err: "RowFilterDefinitions:1:14: Invalid filter '(header1 = 1))': unexpected ')'"},
+		{name: "Unknown keyword", filters: "header1 LIKE x", err: "RowFilterDefinitions:1:15: Invalid filter 'header1 LIKE x': missing comparison operator"},
+		{name: "Malformed line after valid ones", filters: "header1 > 0\n\nheader1 IN (1", err: "RowFilterDefinitions:3:14: Invalid filter 'header1 IN (1': missing ')'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Process(context.Background(), strings.NewReader("header1\n1\n2\n"), &buf, Options{RowFilterDefinitions: tt.filters})
+			assert.EqualError(t, err, tt.err)
+			assert.Equal(t, "", buf.String())
+		})
+	}
+}
+
+func TestProcessEmptyRange(t *testing.T) {
+	for _, selectedColumns := range []string{"..", "-.."} {
+		t.Run(selectedColumns, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Process(context.Background(), strings.NewReader(""), &buf, Options{SelectedColumns: selectedColumns})
+			assert.EqualError(t, err, "SelectedColumns:1:1: Invalid column expression '..': empty range: the header has no columns")
+			assert.Equal(t, "", buf.String())
+
+			q, err := Prepare([]string{}, Options{SelectedColumns: selectedColumns})
+			assert.Nil(t, q)
+			assert.EqualError(t, err, "SelectedColumns:1:1: Invalid column expression '..': empty range: the header has no columns")
+		})
+	}
+}
+
+func TestProcessUnknownColumnType(t *testing.T) {
+	var buf bytes.Buffer
+	err := Process(context.Background(), strings.NewReader("header1\n1\n"), &buf, Options{ColumnTypes: map[string]ColumnType{"header2": TypeInteger}})
+	assert.Equal(t, &UnknownColumnError{Position: Position{Source: "ColumnTypes"}, Column: "header2"}, err)
+}
+
+func TestProcessInvalidDialect(t *testing.T) {
+	var buf bytes.Buffer
+	err := Process(context.Background(), strings.NewReader("header1\n1\n"), &buf, Options{OutputDialect: Dialect{Delimiter: '\n'}})
+	assert.EqualError(t, err, "invalid dialect: '\\n' is not a valid delimiter")
+	assert.Equal(t, "", buf.String())
+}
+
+func TestProcessUnencodableOutput(t *testing.T) {
+	var buf bytes.Buffer
+	err := Process(context.Background(), strings.NewReader("a,b\n\"x|y\",2\n"), &buf, Options{OutputDialect: Dialect{Delimiter: '|', Quote: NoQuote}})
+	assert.ErrorIs(t, err, ErrUnencodable)
+	assert.EqualError(t, err, "field cannot be written without quote or escape: \"x|y\"")
+	assert.Equal(t, "a|b\n", buf.String())
+}
+
+func TestProcessRaggedRows(t *testing.T) {
+	csvData := "id,name\n1,a\n2\n3,\"c\nd\",x\n4,d\n"
+	var buf, rejects bytes.Buffer
+	var stats Stats
+	opts := Options{ShortRows: RaggedFit, LongRows: RaggedQuarantine, Rejects: &rejects, Stats: &stats}
+	err := Process(context.Background(), strings.NewReader(csvData), &buf, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n1,a\n2,\n4,d\n", buf.String())
+	assert.Equal(t, "4,too many fields,3,\"c\nd\",x\n", rejects.String())
+	assert.Equal(t, Stats{Rows: 4, Written: 3, ShortRows: 1, LongRows: 1, Rejected: 1}, stats)
+}
+
+func TestProcessAllowedShortRows(t *testing.T) {
+	var buf bytes.Buffer
+	var stats Stats
+	err := Process(context.Background(), strings.NewReader("a,b,c\n1\n2,x\n"), &buf, Options{SelectedColumns: "c,b,a,upper(b)", Stats: &stats})
+	assert.Nil(t, err)
+	assert.Equal(t, "c,b,a,upper(b)\n,,1,\n,x,2,X\n", buf.String())
+	assert.Equal(t, Stats{Rows: 2, Written: 2, ShortRows: 2}, stats)
+}
+
+func TestProcessStrictRaggedRows(t *testing.T) {
+	var buf bytes.Buffer
+	var stats Stats
+	err := Process(context.Background(), strings.NewReader("id,name\n1,a\n\n2\n3,c\n"), &buf, Options{ShortRows: RaggedStrict, Stats: &stats})
+	assert.EqualError(t, err, "parse error on line 4: wrong number of fields")
+	assert.ErrorIs(t, err, ErrFieldCount)
+	assert.Equal(t, "id,name\n1,a\n", buf.String())
+	assert.Equal(t, Stats{Rows: 2, Written: 1, ShortRows: 1}, stats)
+}
+
+func TestProcessLenient(t *testing.T) {
+	csvData := "id,name\n1,a\n2,b\"c\n3\n4,d\n"
+	var buf, rejects bytes.Buffer
+	var stats Stats
+	opts := Options{Lenient: true, ShortRows: RaggedQuarantine, Rejects: &rejects, Stats: &stats}
+	err := Process(context.Background(), strings.NewReader(csvData), &buf, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n1,a\n4,d\n", buf.String())
+	assert.Equal(t, "3,\"bare \"\" in non-quoted field (line 3, column 4)\",\"2,b\"\"c\"\n4,too few fields,3\n", rejects.String())
+	assert.Equal(t, Stats{Rows: 3, Written: 2, ShortRows: 1, Malformed: 1, Rejected: 2}, stats)
+}
+
+func TestProcessLenientHeaderless(t *testing.T) {
+	csvData := "1\"x,2\n3,4\n"
+	var buf, rejects bytes.Buffer
+	var stats Stats
+	opts := Options{NoHeader: true, Lenient: true, Rejects: &rejects, Stats: &stats}
+	err := Process(context.Background(), strings.NewReader(csvData), &buf, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "c1,c2\n3,4\n", buf.String())
+	assert.Equal(t, "1,\"bare \"\" in non-quoted field (line 1, column 2)\",\"1\"\"x,2\"\n", rejects.String())
+	assert.Equal(t, Stats{Rows: 1, Written: 1, Malformed: 1, Rejected: 1}, stats)
+}
+
+func TestProcessMaxRejects(t *testing.T) {
+	csvData := "id,name\n1,\"a\"b\n2,b\"c\n3,c\n"
+	var buf bytes.Buffer
+	err := Process(context.Background(), strings.NewReader(csvData), &buf, Options{Lenient: true, MaxRejects: 1})
+	assert.ErrorIs(t, err, ErrTooManyRejects)
+	assert.Equal(t, "id,name\n", buf.String())
+}
+
+func TestProcessCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	err := Process(ctx, strings.NewReader("header1\n1\n2\n"), &buf, Options{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, "header1\n", buf.String())
+}
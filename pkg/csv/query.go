@@ -0,0 +1,141 @@
+package csv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrHeaderMismatch is the error of a ParseError for an input whose header
+// differs from the header a Query was prepared with.
+var ErrHeaderMismatch = errors.New("header does not match the query")
+
+// Query is the compiled form of the selection, column types, null tokens
+// and filters of Options, with column names resolved to indices in a
+// header. It can be run any number of times against inputs with that
+// header, and each run returns its own Stats. Runs may proceed
+// concurrently, provided that the Rejects and OnReject of the options can
+// be shared by them.
+type Query struct {
+	opts    Options
+	header  CsvHeader
+	filters FilterExpr
+}
+
+// Prepare compiles opts against the column names of header, reporting any
+// unknown column or malformed definition. The input options of opts, such
+// as InputDialect and NoHeader, apply to every run of the query, but
+// ColumnNames is ignored since header names the columns, and Stats is
+// ignored since every run returns its own.
+func Prepare(header []string, opts Options) (*Query, error) {
+	opts.Stats = nil
+	return compileQuery(CsvHeader{headers: append([]string(nil), header...)}, opts)
+}
+
+// compileQuery resolves opts against header, reporting any unknown column
+// or malformed definition before a record is processed.
+func compileQuery(header CsvHeader, opts Options) (*Query, error) {
+	header.collation = opts.Collation
+	if err := parseSelectedColumns(opts.SelectedColumns, &header); err != nil {
+		return nil, err
+	}
+	if err := header.setColumnTypes(opts.ColumnTypes); err != nil {
+		return nil, err
+	}
+	header.nullTokens = opts.NullTokens
+	filters, err := ParseFilters(opts.RowFilterDefinitions, header)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{opts: opts, header: header, filters: bindFilters(filters, header)}, nil
+}
+
+// Header returns the column names the query was prepared with.
+func (q *Query) Header() []string {
+	return append([]string(nil), q.header.headers...)
+}
+
+// Run reads CSV records from r and writes the selected columns of the rows
+// that pass the filters of the query to w, like Process, and returns the
+// counts of the run. Unless the input has no header, its first record must
+// equal the header of the query, or Run fails with a ParseError wrapping
+// ErrHeaderMismatch before anything is written.
+func (q *Query) Run(ctx context.Context, r io.Reader, w io.Writer) (Stats, error) {
+	return q.runSource(ctx, r, w, q.opts.Source)
+}
+
+// RunFile is like Run but reads the CSV records from the file at
+// csvFilePath, which names the input in errors unless the options of the
+// query set Source.
+func (q *Query) RunFile(ctx context.Context, csvFilePath string, w io.Writer) (Stats, error) {
+	file, err := os.Open(csvFilePath)
+	if err != nil {
+		return Stats{}, fmt.Errorf("Failed to open file %s: %w", csvFilePath, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	source := q.opts.Source
+	if source == "" {
+		source = csvFilePath
+	}
+	return q.runSource(ctx, file, w, source)
+}
+
+func (q *Query) runSource(ctx context.Context, r io.Reader, w io.Writer, source string) (Stats, error) {
+	reader, noHeader, err := openReader(r, q.opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	reader.source = source
+	if !noHeader {
+		if err := q.checkHeader(reader); err != nil {
+			return Stats{}, err
+		}
+	}
+	return q.run(ctx, reader, w)
+}
+
+// checkHeader consumes the header of reader and compares it with the
+// header of the query. An empty input has no header to compare.
+func (q *Query) checkHeader(reader *recordReader) error {
+	record, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	headers := q.header.headers
+	for i := 0; i < len(record) || i < len(headers); i++ {
+		if i >= len(record) || i >= len(headers) || record[i] != headers[i] {
+			pos := reader.recordPosition()
+			pos.Field = i + 1
+			return &ParseError{Position: pos, Err: ErrHeaderMismatch}
+		}
+	}
+	return nil
+}
+
+// run streams the records of reader, positioned past the header, through
+// the query to w and returns the counts.
+func (q *Query) run(ctx context.Context, reader *recordReader, w io.Writer) (Stats, error) {
+	var stats Stats
+	ragged := &raggedRows{
+		short:   q.opts.ShortRows,
+		long:    q.opts.LongRows,
+		width:   len(q.header.headers),
+		rejects: newRejectWriter(q.opts, &stats),
+		stats:   &stats,
+	}
+	writer := newRecordWriter(w, q.opts.OutputDialect)
+	err := writeCsvData(ctx, reader, writer, q.header, q.filters, ragged, &stats)
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	if flushErr := ragged.rejects.Flush(); err == nil {
+		err = flushErr
+	}
+	return stats, err
+}
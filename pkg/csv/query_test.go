@@ -0,0 +1,253 @@
+package csv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompileQuery(t *testing.T) {
+	header := CsvHeader{headers: []string{"id", "Name", "amount"}}
+	q, err := compileQuery(header, Options{
+		SelectedColumns:      "name,id",
+		RowFilterDefinitions: "amount > 10",
+		ColumnTypes:          map[string]ColumnType{"amount": TypeDecimal},
+		NullTokens:           []string{"NULL"},
+		Collation:            CollateNoCase,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 0}, q.header.selectedIndices)
+	assert.Equal(t, map[string]ColumnType{"amount": TypeDecimal}, q.header.columnTypes)
+	assert.Equal(t, []string{"NULL"}, q.header.nullTokens)
+	assert.NotNil(t, q.filters)
+	assert.Nil(t, header.selectedIndices)
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	header := CsvHeader{headers: []string{"id", "name", "amount"}}
+	tests := []struct {
+		name     string
+		opts     Options
+		expected error
+	}{
+		{
+			name:     "Unknown selected column",
+			opts:     Options{SelectedColumns: "id,total"},
+			expected: &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 4, Offset: 3, Field: 2}, Column: "total"},
+		},
+		{
+			name:     "Unknown typed column",
+			opts:     Options{ColumnTypes: map[string]ColumnType{"total": TypeInteger}},
+			expected: &UnknownColumnError{Position: Position{Source: "ColumnTypes"}, Column: "total"},
+		},
+		{
+			name:     "Filter without operator",
+			opts:     Options{RowFilterDefinitions: "amount"},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 7, Offset: 6}, Definition: "amount", Msg: "missing comparison operator"},
+		},
+		{
+			name:     "Unknown filtered column",
+			opts:     Options{RowFilterDefinitions: "id > 1\ntotal > 10"},
+			expected: &UnknownColumnError{Position: Position{Source: "RowFilterDefinitions", Line: 2, Column: 1, Offset: 7}, Column: "total"},
+		},
+		{
+			name:     "Unknown operator",
+			opts:     Options{RowFilterDefinitions: "amount => 10"},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 8, Offset: 7}, Definition: "amount => 10", Msg: "unknown comparison operator '=>'"},
+		},
+		{
+			name:     "Unbalanced parenthesis",
+			opts:     Options{RowFilterDefinitions: "(id = 1 OR id = 2"},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 18, Offset: 17}, Definition: "(id = 1 OR id = 2", Msg: "missing ')'"},
+		},
+		{
+			name:     "Dangling operator",
+			opts:     Options{RowFilterDefinitions: "id = 1 AND"},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 11, Offset: 10}, Definition: "id = 1 AND", Msg: "missing comparison operator"},
+		},
+		{
+			name:     "Invalid regular expression",
+			opts:     Options{RowFilterDefinitions: "name MATCHES a("},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 14, Offset: 13}, Definition: "name MATCHES a(", Msg: "invalid regular expression: error parsing regexp: missing closing ): `a(`"},
+		},
+		{
+			name:     "Value invalid for the declared type",
+			opts:     Options{RowFilterDefinitions: "amount > ten", ColumnTypes: map[string]ColumnType{"amount": TypeDecimal}},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 10, Offset: 9}, Definition: "amount > ten", Msg: "'ten' is not a valid decimal"},
+		},
+		{
+			name:     "Unknown collation",
+			opts:     Options{RowFilterDefinitions: "name = x COLLATE FRENCH"},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 18, Offset: 17}, Definition: "name = x COLLATE FRENCH", Msg: "unknown collation 'FRENCH'"},
+		},
+		{
+			name:     "Unterminated quoted value",
+			opts:     Options{RowFilterDefinitions: "name = 'x"},
+			expected: &FilterSyntaxError{Position: Position{Source: "RowFilterDefinitions", Line: 1, Column: 8, Offset: 7}, Definition: "name = 'x", Msg: "unterminated quoted value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := compileQuery(header, tt.opts)
+			assert.Nil(t, q)
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	header := []string{"id", "name", "amount"}
+	q, err := Prepare(header, Options{SelectedColumns: "name,amount", RowFilterDefinitions: "amount > 10"})
+	assert.Nil(t, err)
+	header[0] = "changed"
+	assert.Equal(t, []string{"id", "name", "amount"}, q.Header())
+
+	_, err = Prepare(header, Options{RowFilterDefinitions: "total > 10"})
+	var unknownColumnErr *UnknownColumnError
+	assert.True(t, errors.As(err, &unknownColumnErr))
+	assert.Equal(t, "total", unknownColumnErr.Column)
+}
+
+func TestQueryRun(t *testing.T) {
+	q, err := Prepare([]string{"id", "name", "amount"}, Options{
+		SelectedColumns:      "name,amount",
+		RowFilterDefinitions: "amount > 10",
+	})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name           string
+		csvData        string
+		expectedOutput string
+		expectedStats  Stats
+	}{
+		{
+			name:           "First input",
+			csvData:        "id,name,amount\n1,a,5\n2,b,20\n",
+			expectedOutput: "name,amount\nb,20\n",
+			expectedStats:  Stats{Rows: 2, Written: 1},
+		},
+		{
+			name:           "Second input",
+			csvData:        "id,name,amount\n3,c,30\n4,d,40\n5,e,1\n",
+			expectedOutput: "name,amount\nc,30\nd,40\n",
+			expectedStats:  Stats{Rows: 3, Written: 2},
+		},
+		{
+			name:           "Empty input",
+			csvData:        "",
+			expectedOutput: "name,amount\n",
+			expectedStats:  Stats{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			stats, err := q.Run(context.Background(), strings.NewReader(tt.csvData), &buf)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedOutput, buf.String())
+			assert.Equal(t, tt.expectedStats, stats)
+		})
+	}
+}
+
+func TestQueryRunNoHeader(t *testing.T) {
+	q, err := Prepare([]string{"id", "name"}, Options{NoHeader: true, RowFilterDefinitions: "id = 2"})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	_, err = q.Run(context.Background(), strings.NewReader("1,a\n2,b\n"), &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n2,b\n", buf.String())
+}
+
+func TestQueryRunHeaderMismatch(t *testing.T) {
+	q, err := Prepare([]string{"id", "name", "amount"}, Options{Source: "orders"})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		csvData  string
+		expected Position
+	}{
+		{
+			name:     "Different column",
+			csvData:  "id,label,amount\n1,a,5\n",
+			expected: Position{Source: "orders", Line: 1, Field: 2},
+		},
+		{
+			name:     "Missing column",
+			csvData:  "id,name\n1,a\n",
+			expected: Position{Source: "orders", Line: 1, Field: 3},
+		},
+		{
+			name:     "Extra column",
+			csvData:  "id,name,amount,total\n1,a,5,5\n",
+			expected: Position{Source: "orders", Line: 1, Field: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := q.Run(context.Background(), strings.NewReader(tt.csvData), &buf)
+			assert.Equal(t, &ParseError{Position: tt.expected, Err: ErrHeaderMismatch}, err)
+			assert.ErrorIs(t, err, ErrHeaderMismatch)
+			assert.Empty(t, buf.String())
+		})
+	}
+}
+
+func TestQueryRunFile(t *testing.T) {
+	path := t.TempDir() + "/orders.csv"
+	if err := os.WriteFile(path, []byte("id,amount\n1,5\n2,20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	q, err := Prepare([]string{"id", "name"}, Options{})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	_, err = q.RunFile(context.Background(), path, &buf)
+	assert.EqualError(t, err, path+": parse error on line 1: header does not match the query")
+
+	q, err = Prepare([]string{"id", "amount"}, Options{RowFilterDefinitions: "amount > 10"})
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		_, err = q.RunFile(context.Background(), path, &buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "id,amount\n2,20\n", buf.String())
+	}
+
+	missing := t.TempDir() + "/missing.csv"
+	_, err = q.RunFile(context.Background(), missing, &buf)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestQueryRunConcurrently(t *testing.T) {
+	var ignored Stats
+	q, err := Prepare([]string{"id", "amount"}, Options{RowFilterDefinitions: "amount > 10", Stats: &ignored})
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	results := make([]Stats, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			csvData := "id,amount\n" + strings.Repeat("1,5\n2,20\n", i+1)
+			var buf bytes.Buffer
+			results[i], _ = q.Run(context.Background(), strings.NewReader(csvData), &buf)
+		}()
+	}
+	wg.Wait()
+	for i, stats := range results {
+		assert.Equal(t, Stats{Rows: 2 * (i + 1), Written: i + 1}, stats)
+	}
+	assert.Equal(t, Stats{}, ignored)
+}
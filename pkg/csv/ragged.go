@@ -0,0 +1,75 @@
+package csv
+
+import "strconv"
+
+// RaggedPolicy controls what happens to rows with fewer or more fields
+// than the header.
+type RaggedPolicy int
+
+const (
+	// RaggedAllow processes ragged rows as they are: missing fields are
+	// null and extra fields are ignored.
+	RaggedAllow RaggedPolicy = iota
+	// RaggedStrict stops processing with a ParseError wrapping
+	// ErrFieldCount at the first ragged row.
+	RaggedStrict
+	// RaggedFit pads short rows with empty fields and truncates long rows
+	// to the width of the header.
+	RaggedFit
+	// RaggedQuarantine writes ragged rows to Options.Rejects instead of
+	// processing them.
+	RaggedQuarantine
+)
+
+func (p RaggedPolicy) String() string {
+	switch p {
+	case RaggedAllow:
+		return "allow"
+	case RaggedStrict:
+		return "strict"
+	case RaggedFit:
+		return "fit"
+	case RaggedQuarantine:
+		return "quarantine"
+	default:
+		return "RaggedPolicy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// raggedRows applies the policies for short and long rows to the rows of
+// a header of the given width, counting them in stats.
+type raggedRows struct {
+	short, long RaggedPolicy
+	width       int
+	rejects     *rejectWriter
+	stats       *Stats
+}
+
+// check returns row as it should be processed, or nil if it was
+// quarantined. pos is the position of the start of row.
+func (p *raggedRows) check(row []string, pos Position) ([]string, error) {
+	if p == nil || len(row) == p.width {
+		return row, nil
+	}
+	policy, reason := p.short, "too few fields"
+	if len(row) < p.width {
+		p.stats.ShortRows++
+	} else {
+		policy, reason = p.long, "too many fields"
+		p.stats.LongRows++
+	}
+	switch policy {
+	case RaggedStrict:
+		pos.Field = min(len(row), p.width) + 1
+		return nil, &ParseError{Position: pos, Err: ErrFieldCount}
+	case RaggedFit:
+		if len(row) > p.width {
+			return row[:p.width], nil
+		}
+		return append(row, make([]string, p.width-len(row))...), nil
+	case RaggedQuarantine:
+		return nil, p.rejects.Reject(pos.Line, reason, row)
+	default:
+		return row, nil
+	}
+}
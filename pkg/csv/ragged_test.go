@@ -0,0 +1,88 @@
+package csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRaggedRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		short    RaggedPolicy
+		long     RaggedPolicy
+		row      []string
+		expected []string
+		err      error
+		rejects  string
+		stats    Stats
+	}{
+		{
+			name:     "Row of the header width",
+			short:    RaggedStrict,
+			long:     RaggedStrict,
+			row:      []string{"1", "2", "3"},
+			expected: []string{"1", "2", "3"},
+		},
+		{
+			name:     "Short row allowed",
+			row:      []string{"1"},
+			expected: []string{"1"},
+			stats:    Stats{ShortRows: 1},
+		},
+		{
+			name:  "Short row strict",
+			short: RaggedStrict,
+			row:   []string{"1"},
+			err:   &ParseError{Position: Position{Line: 7, Offset: 20, Field: 2}, Err: ErrFieldCount},
+			stats: Stats{ShortRows: 1},
+		},
+		{
+			name:     "Short row fitted",
+			short:    RaggedFit,
+			row:      []string{"1"},
+			expected: []string{"1", "", ""},
+			stats:    Stats{ShortRows: 1},
+		},
+		{
+			name:     "Long row fitted",
+			long:     RaggedFit,
+			row:      []string{"1", "2", "3", "4"},
+			expected: []string{"1", "2", "3"},
+			stats:    Stats{LongRows: 1},
+		},
+		{
+			name:    "Long row quarantined",
+			long:    RaggedQuarantine,
+			row:     []string{"1", "2", "3", "4,5"},
+			rejects: "7,too many fields,1,2,3,\"4,5\"\n",
+			stats:   Stats{LongRows: 1, Rejected: 1},
+		},
+		{
+			name:     "Long row allowed when short rows are quarantined",
+			short:    RaggedQuarantine,
+			row:      []string{"1", "2", "3", "4"},
+			expected: []string{"1", "2", "3", "4"},
+			stats:    Stats{LongRows: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rejects bytes.Buffer
+			var stats Stats
+			ragged := &raggedRows{short: tt.short, long: tt.long, width: 3, rejects: newRejectWriter(Options{Rejects: &rejects}, &stats), stats: &stats}
+			row, err := ragged.check(tt.row, Position{Line: 7, Offset: 20})
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.expected, row)
+			assert.Nil(t, ragged.rejects.Flush())
+			assert.Equal(t, tt.rejects, rejects.String())
+			assert.Equal(t, tt.stats, stats)
+		})
+	}
+}
+
+func TestRaggedPolicyString(t *testing.T) {
+	assert.Equal(t, "quarantine", RaggedQuarantine.String())
+	assert.Equal(t, "RaggedPolicy(9)", RaggedPolicy(9).String())
+}
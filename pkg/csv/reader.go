@@ -0,0 +1,294 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	ErrBareQuote  = errors.New("bare \" in non-quoted field")
+	ErrQuote      = errors.New("extraneous or missing \" in quoted field")
+	ErrEscape     = errors.New("escape character at end of input")
+	ErrFieldCount = errors.New("wrong number of fields")
+)
+
+// ParseError reports the position of a malformed record. A zero Column
+// means the whole record is at fault.
+type ParseError struct {
+	Position
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var source string
+	if e.Source != "" {
+		source = e.Source + ": "
+	}
+	if e.Column == 0 {
+		return fmt.Sprintf("%sparse error on line %d: %v", source, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%sparse error on line %d, column %d: %v", source, e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// recordReader reads records in a Dialect, by default RFC 4180. Quoted
+// fields may contain delimiters, escaped quotes and line breaks; empty
+// lines between records are skipped.
+type recordReader struct {
+	r *bufio.Reader
+	// source names the input in errors.
+	source  string
+	line    int
+	lineBuf []byte
+	// offset is the number of bytes read and lineOffset the offset of the
+	// current line.
+	offset     int64
+	lineOffset int64
+	// recordLine and recordOffset locate the start of the last record
+	// read.
+	recordLine   int
+	recordOffset int64
+	// keepRaw makes Read keep the lines of the last record in raw.
+	keepRaw   bool
+	raw       []byte
+	delimiter byte
+	quote     byte
+	escape    byte
+	// fieldStops and quotedStops are the bytes that end a run of plain
+	// text in unquoted and quoted fields.
+	fieldStops  string
+	quotedStops string
+	// peeked holds the records read ahead by Peek, in order, to be
+	// returned again by Read.
+	peeked []peekedRecord
+}
+
+// peekedRecord is a record read ahead, with its error and the position and
+// raw text it was read with.
+type peekedRecord struct {
+	record []string
+	err    error
+	line   int
+	offset int64
+	raw    []byte
+}
+
+func newRecordReader(r io.Reader, d Dialect) *recordReader {
+	reader := &recordReader{r: bufio.NewReader(r), delimiter: d.delimiter(), quote: d.quote(), escape: d.escape()}
+	reader.fieldStops = string([]byte{reader.delimiter, '\n'})
+	for _, c := range []byte{reader.quote, reader.escape} {
+		if c != 0 {
+			reader.fieldStops += string(c)
+			reader.quotedStops += string(c)
+		}
+	}
+	return reader
+}
+
+// Peek returns the next record without consuming it, or io.EOF. With
+// skipMalformed, records failing with a ParseError are read past instead of
+// returned, and Read returns them, errors included, before the record Peek
+// returned.
+func (r *recordReader) Peek(skipMalformed bool) ([]string, error) {
+	for {
+		record, err := r.readRecord()
+		var parseErr *ParseError
+		malformed := errors.As(err, &parseErr)
+		if err == nil || malformed && skipMalformed {
+			r.peeked = append(r.peeked, peekedRecord{record: record, err: err, line: r.recordLine, offset: r.recordOffset, raw: append([]byte(nil), r.raw...)})
+		}
+		if !malformed || !skipMalformed {
+			return record, err
+		}
+	}
+}
+
+// recordPosition returns the position of the start of the last record
+// read.
+func (r *recordReader) recordPosition() Position {
+	return Position{Source: r.source, Line: r.recordLine, Offset: r.recordOffset}
+}
+
+// errorAt returns a ParseError at column of the current line, in the
+// field-th field of the record.
+func (r *recordReader) errorAt(column, field int, err error) *ParseError {
+	return &ParseError{Position: Position{Source: r.source, Line: r.line, Column: column, Offset: r.lineOffset + int64(column-1), Field: field}, Err: err}
+}
+
+// rawRecord returns the text of the last record read, or of the part of it
+// read before a ParseError, if keepRaw is set. Line breaks are normalised
+// to "\n" and the last one is dropped.
+func (r *recordReader) rawRecord() string {
+	return strings.TrimSuffix(string(r.raw), "\n")
+}
+
+// nextLine is readLine for the lines continuing a record.
+func (r *recordReader) nextLine() ([]byte, error) {
+	line, err := r.readLine()
+	if err == nil && r.keepRaw {
+		r.raw = append(r.raw, line...)
+	}
+	return line, err
+}
+
+// readLine returns the next physical line, always terminated by "\n" and
+// with "\r\n" normalised to "\n". The returned slice is only valid until
+// the next call.
+func (r *recordReader) readLine() ([]byte, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		r.lineBuf = append(r.lineBuf[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = r.r.ReadSlice('\n')
+			r.lineBuf = append(r.lineBuf, line...)
+		}
+		line = r.lineBuf
+	}
+	if len(line) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	r.line++
+	n := len(line)
+	r.lineOffset = r.offset
+	r.offset += int64(n)
+	switch {
+	case n >= 2 && line[n-2] == '\r' && line[n-1] == '\n':
+		line[n-2] = '\n'
+		line = line[:n-1]
+	case line[n-1] == '\r':
+		line[n-1] = '\n'
+	case line[n-1] != '\n':
+		r.lineBuf = append(append(r.lineBuf[:0], line...), '\n')
+		line = r.lineBuf
+	}
+	return line, nil
+}
+
+// Read returns the next record or io.EOF when the input is exhausted.
+// After a ParseError the next call resumes at the line following the one
+// at fault.
+func (r *recordReader) Read() ([]string, error) {
+	if len(r.peeked) > 0 {
+		p := r.peeked[0]
+		r.peeked = r.peeked[1:]
+		r.recordLine, r.recordOffset, r.raw = p.line, p.offset, p.raw
+		return p.record, p.err
+	}
+	return r.readRecord()
+}
+
+// readRecord reads the next record from the input, past the peeked ones.
+func (r *recordReader) readRecord() ([]string, error) {
+	line, err := r.readLine()
+	for err == nil && len(line) == 1 {
+		line, err = r.readLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.recordLine, r.recordOffset = r.line, r.lineOffset
+	if r.keepRaw {
+		r.raw = append(r.raw[:0], line...)
+	}
+
+	var record []string
+	column := 1
+	for {
+		if r.quote == 0 || line[0] != r.quote {
+			var field []byte
+			for {
+				i := bytes.IndexAny(line, r.fieldStops)
+				field = append(field, line[:i]...)
+				c := line[i]
+				column += i
+				line = line[i:]
+				if c == r.delimiter || c == '\n' {
+					break
+				}
+				if c == r.quote {
+					return nil, r.errorAt(column, len(record)+1, ErrBareQuote)
+				}
+				field = append(field, line[1])
+				if line[1] != '\n' {
+					line = line[2:]
+					column += 2
+					continue
+				}
+				if line, err = r.nextLine(); err != nil {
+					if err == io.EOF {
+						err = r.errorAt(column, len(record)+1, ErrEscape)
+					}
+					return nil, err
+				}
+				column = 1
+			}
+			record = append(record, string(field))
+			if line[0] == '\n' {
+				return record, nil
+			}
+			line = line[1:]
+			column++
+			continue
+		}
+
+		start := Position{Source: r.source, Line: r.line, Column: column, Offset: r.lineOffset + int64(column-1), Field: len(record) + 1}
+		line = line[1:]
+		column++
+		var field []byte
+		for {
+			i := bytes.IndexAny(line, r.quotedStops)
+			if i < 0 {
+				field = append(field, line...)
+				line, err = r.nextLine()
+				if err == io.EOF {
+					return nil, &ParseError{Position: start, Err: ErrQuote}
+				}
+				if err != nil {
+					return nil, err
+				}
+				column = 1
+				continue
+			}
+			field = append(field, line[:i]...)
+			c := line[i]
+			line = line[i+1:]
+			column += i + 1
+			if c == r.escape {
+				field = append(field, line[0])
+				line = line[1:]
+				column++
+				continue
+			}
+			if r.escape == 0 && line[0] == r.quote {
+				field = append(field, r.quote)
+				line = line[1:]
+				column++
+				continue
+			}
+			break
+		}
+		record = append(record, string(field))
+		switch line[0] {
+		case r.delimiter:
+			line = line[1:]
+			column++
+		case '\n':
+			return record, nil
+		default:
+			return nil, r.errorAt(column, len(record), ErrQuote)
+		}
+	}
+}
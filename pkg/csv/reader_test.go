@@ -0,0 +1,187 @@
+package csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecordReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		dialect  Dialect
+		expected [][]string
+	}{
+		{
+			name:     "Simple records",
+			input:    "a,b,c\n1,2,3\n",
+			expected: [][]string{{"a", "b", "c"}, {"1", "2", "3"}},
+		},
+		{
+			name:     "No trailing newline",
+			input:    "a,b\n1,2",
+			expected: [][]string{{"a", "b"}, {"1", "2"}},
+		},
+		{
+			name:     "CRLF line endings",
+			input:    "a,b\r\n1,2\r\n",
+			expected: [][]string{{"a", "b"}, {"1", "2"}},
+		},
+		{
+			name:     "Empty lines are skipped",
+			input:    "a,b\n\n1,2\n\n",
+			expected: [][]string{{"a", "b"}, {"1", "2"}},
+		},
+		{
+			name:     "Empty fields",
+			input:    ",,\n",
+			expected: [][]string{{"", "", ""}},
+		},
+		{
+			name:     "Quoted field with comma",
+			input:    "\"a,b\",c\n",
+			expected: [][]string{{"a,b", "c"}},
+		},
+		{
+			name:     "Doubled quotes",
+			input:    "\"say \"\"hi\"\"\",\"\"\"\"\n",
+			expected: [][]string{{"say \"hi\"", "\""}},
+		},
+		{
+			name:     "Multi-line field",
+			input:    "\"line1\r\nline2\",x\n1,2\n",
+			expected: [][]string{{"line1\nline2", "x"}, {"1", "2"}},
+		},
+		{
+			name:     "Quoted empty field",
+			input:    "\"\",a\n",
+			expected: [][]string{{"", "a"}},
+		},
+		{
+			name:     "Empty input",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:     "Semicolon delimiter",
+			input:    "a;\"b;c\";d,e\n",
+			dialect:  Dialect{Delimiter: ';'},
+			expected: [][]string{{"a", "b;c", "d,e"}},
+		},
+		{
+			name:     "Single quotes",
+			input:    "'it''s',\"x\"\n",
+			dialect:  Dialect{Quote: '\''},
+			expected: [][]string{{"it's", "\"x\""}},
+		},
+		{
+			name:     "Backslash escape in quoted field",
+			input:    "\"say \\\"hi\\\"\",\"a\\\\b\"\n",
+			dialect:  Dialect{Escape: '\\'},
+			expected: [][]string{{"say \"hi\"", "a\\b"}},
+		},
+		{
+			name:     "TSV keeps backslashes",
+			input:    "a\tC:\\temp\t\"d\"\n\\N\tb\\\n",
+			dialect:  TSV,
+			expected: [][]string{{"a", "C:\\temp", "\"d\""}, {"\\N", "b\\"}},
+		},
+		{
+			name:     "Tab-separated with backslash escape",
+			input:    "a\tb\\\tc\t\"d\"\nline1\\\nline2\t\\\\\n",
+			dialect:  Dialect{Delimiter: '\t', Quote: NoQuote, Escape: '\\'},
+			expected: [][]string{{"a", "b\tc", "\"d\""}, {"line1\nline2", "\\"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newRecordReader(strings.NewReader(tt.input), tt.dialect)
+			var result [][]string
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				assert.Nil(t, err)
+				result = append(result, record)
+			}
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestRecordReaderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		dialect  Dialect
+		expected error
+	}{
+		{
+			name:     "Bare quote",
+			input:    "a,b\"c\n",
+			expected: &ParseError{Position: Position{Line: 1, Column: 4, Offset: 3, Field: 2}, Err: ErrBareQuote},
+		},
+		{
+			name:     "Text after closing quote",
+			input:    "a\n\"b\"c,d\n",
+			expected: &ParseError{Position: Position{Line: 2, Column: 4, Offset: 5, Field: 1}, Err: ErrQuote},
+		},
+		{
+			name:     "Unterminated quoted field",
+			input:    "a,b\n1,\"2\n3\n",
+			expected: &ParseError{Position: Position{Line: 2, Column: 3, Offset: 6, Field: 2}, Err: ErrQuote},
+		},
+		{
+			name:     "Escape at end of input",
+			input:    "a\tb\\",
+			dialect:  Dialect{Delimiter: '\t', Quote: NoQuote, Escape: '\\'},
+			expected: &ParseError{Position: Position{Line: 1, Column: 4, Offset: 3, Field: 2}, Err: ErrEscape},
+		},
+		{
+			name:     "Bare quote with custom quote",
+			input:    "a|b'c\n",
+			dialect:  Dialect{Delimiter: '|', Quote: '\''},
+			expected: &ParseError{Position: Position{Line: 1, Column: 4, Offset: 3, Field: 2}, Err: ErrBareQuote},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newRecordReader(strings.NewReader(tt.input), tt.dialect)
+			var err error
+			for err == nil {
+				_, err = reader.Read()
+			}
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestRecordReaderResumesAfterError(t *testing.T) {
+	reader := newRecordReader(strings.NewReader("a,b\n1,\"x\ny\"z,2\n\n3,4\r\n5,b\"c\n"), Dialect{})
+	reader.keepRaw = true
+	type result struct {
+		record []string
+		err    error
+		line   int
+		raw    string
+	}
+	var results []result
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		results = append(results, result{record, err, reader.recordLine, reader.rawRecord()})
+	}
+	assert.Equal(t, []result{
+		{record: []string{"a", "b"}, line: 1, raw: "a,b"},
+		{err: &ParseError{Position: Position{Line: 3, Column: 3, Offset: 11, Field: 2}, Err: ErrQuote}, line: 2, raw: "1,\"x\ny\"z,2"},
+		{record: []string{"3", "4"}, line: 5, raw: "3,4"},
+		{err: &ParseError{Position: Position{Line: 6, Column: 4, Offset: 24, Field: 2}, Err: ErrBareQuote}, line: 6, raw: "5,b\"c"},
+	}, results)
+}
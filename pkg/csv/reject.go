@@ -0,0 +1,68 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrTooManyRejects is returned once more records have been rejected than
+// Options.MaxRejects allows.
+var ErrTooManyRejects = errors.New("too many rejected records")
+
+// rejectWriter writes rejected rows as records made of the line on which
+// the row starts, the reason it was rejected and its fields, hands them to
+// the OnReject callback and counts them against the error budget.
+type rejectWriter struct {
+	w        *recordWriter
+	onReject func(line int, reason string, fields []string) error
+	lenient  bool
+	max      int
+	stats    *Stats
+}
+
+func newRejectWriter(opts Options, stats *Stats) *rejectWriter {
+	w := opts.Rejects
+	if w == nil {
+		w = io.Discard
+	}
+	return &rejectWriter{
+		w:        newRecordWriter(w, opts.OutputDialect),
+		onReject: opts.OnReject,
+		lenient:  opts.Lenient,
+		max:      opts.MaxRejects,
+		stats:    stats,
+	}
+}
+
+func (r *rejectWriter) Reject(line int, reason string, row []string) error {
+	r.stats.Rejected++
+	if err := r.w.Write(append([]string{strconv.Itoa(line), reason}, row...)); err != nil {
+		return err
+	}
+	if r.onReject != nil {
+		if err := r.onReject(line, reason, row); err != nil {
+			return err
+		}
+	}
+	if r.max > 0 && r.stats.Rejected > r.max {
+		return fmt.Errorf("%w: more than %d", ErrTooManyRejects, r.max)
+	}
+	return nil
+}
+
+// malformed rejects the record starting on line that failed to parse with
+// err, raw being its text, if r is lenient. Otherwise it returns err.
+func (r *rejectWriter) malformed(err *ParseError, line int, raw string) error {
+	if r == nil || !r.lenient {
+		return err
+	}
+	r.stats.Malformed++
+	reason := fmt.Sprintf("%v (line %d, column %d)", err.Err, err.Line, err.Column)
+	return r.Reject(line, reason, []string{raw})
+}
+
+func (r *rejectWriter) Flush() error {
+	return r.w.Flush()
+}
@@ -0,0 +1,51 @@
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRejectWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var stats Stats
+	var called []string
+	rejects := newRejectWriter(Options{
+		Rejects:       &buf,
+		OutputDialect: Dialect{Delimiter: ';'},
+		OnReject: func(line int, reason string, fields []string) error {
+			called = append(called, reason)
+			return nil
+		},
+		Lenient:    true,
+		MaxRejects: 2,
+	}, &stats)
+
+	assert.Nil(t, rejects.Reject(2, "too few fields", []string{"1"}))
+	assert.Nil(t, rejects.malformed(&ParseError{Position: Position{Line: 4, Column: 3}, Err: ErrBareQuote}, 3, "1;2\""))
+	err := rejects.Reject(5, "too many fields", []string{"1", "2", "3"})
+	assert.ErrorIs(t, err, ErrTooManyRejects)
+	assert.EqualError(t, err, "too many rejected records: more than 2")
+	assert.Nil(t, rejects.Flush())
+	assert.Equal(t, "2;too few fields;1\n3;\"bare \"\" in non-quoted field (line 4, column 3)\";\"1;2\"\"\"\n5;too many fields;1;2;3\n", buf.String())
+	assert.Equal(t, []string{"too few fields", "bare \" in non-quoted field (line 4, column 3)", "too many fields"}, called)
+	assert.Equal(t, Stats{Malformed: 1, Rejected: 3}, stats)
+}
+
+func TestRejectWriterNotLenient(t *testing.T) {
+	parseErr := &ParseError{Position: Position{Line: 1, Column: 2}, Err: ErrQuote}
+	var stats Stats
+	assert.Equal(t, parseErr, newRejectWriter(Options{}, &stats).malformed(parseErr, 1, "x"))
+	assert.Equal(t, Stats{}, stats)
+
+	var rejects *rejectWriter
+	assert.Equal(t, parseErr, rejects.malformed(parseErr, 1, "x"))
+}
+
+func TestRejectWriterCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	var stats Stats
+	rejects := newRejectWriter(Options{OnReject: func(int, string, []string) error { return stop }}, &stats)
+	assert.Equal(t, stop, rejects.Reject(1, "too few fields", nil))
+}
@@ -2,40 +2,359 @@ package csv
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
+// parseSelectedColumns resolves the comma-separated selection list into
+// csvHeader.selectedIndices, in the order the entries are listed. An entry
+// is one of:
+//
+//   - a header name or a 1-based column position, such as "3", optionally
+//     followed by "AS alias" to output it under another name;
+//   - a range of names or positions, such as "col2..col9" or "3..", which
+//     selects the columns between both ends in that order, or in reverse
+//     order if the first end comes after the second;
+//   - a glob such as "amount_*", or a regular expression enclosed in
+//     slashes such as "/^amount_[0-9]+$/", which select the matching
+//     columns in header order;
+//   - any of the above but an alias prefixed with "-", which excludes the
+//     columns from the selection, or from all columns if nothing else is
+//     selected;
+//   - an expression computing a new column, such as "price * qty AS total"
+//     or "upper(name)", which is selected by an index past the end of the
+//     header.
+//
+// Header names take precedence over every other form, so a column named
+// "2" or "a*b" is selected by name, and the other selectors take
+// precedence over expressions, so "-price" excludes the price column
+// rather than negating it, unless a glob such as "price*qty" matches no
+// column and is read as an expression. An empty list selects every
+// column. Errors are located in selectedColumns under the name
+// "SelectedColumns".
 func parseSelectedColumns(selectedColumns string, csvHeader *CsvHeader) error {
-	columns := strings.Split(strings.Trim(selectedColumns, ""), ",")
+	columns := splitSelection(selectedColumns)
 
 	if columns[0] == "" {
-		columns = csvHeader.headers
+		columns = nil
+		for i := range csvHeader.headers {
+			csvHeader.selectedIndices = append(csvHeader.selectedIndices, i)
+		}
 	}
 
-	for _, col := range columns {
-		unknown := true
-		for i, header := range csvHeader.headers {
-			if header == col {
-				csvHeader.selectedIndices = append(csvHeader.selectedIndices, i)
-				unknown = false
-				break
+	var excluded []bool
+	offset := 0
+	for k, col := range columns {
+		if k > 0 {
+			offset += len(columns[k-1]) + 1
+		}
+		locateEntry := func(err error) error {
+			return locate(err, "SelectedColumns", 1, offset+1, int64(offset), k+1)
+		}
+		if i := csvHeader.indexOf(col, csvHeader.collation); i != -1 {
+			csvHeader.selectedIndices = append(csvHeader.selectedIndices, i)
+			continue
+		}
+		// A glob without matches may still be an expression such as
+		// "price*qty", so its error is only reported if that fails too.
+		var globErr error
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(col), "-"); ok {
+			indices, ok, err := csvHeader.resolveSelector(rest)
+			if err != nil && !isUnmatchedGlob(rest, err) {
+				return locateEntry(err)
+			}
+			globErr = err
+			if ok {
+				if excluded == nil {
+					excluded = make([]bool, len(csvHeader.headers))
+				}
+				for _, i := range indices {
+					excluded[i] = true
+				}
+				continue
+			}
+		}
+		// Without this "1 AS id" would be read as the number 1.
+		if selector, alias, ok := cutAlias(col); ok {
+			indices, ok, err := csvHeader.resolveSelector(selector)
+			if err != nil && !isUnmatchedGlob(selector, err) {
+				return locateEntry(err)
+			}
+			if ok {
+				if len(indices) != 1 {
+					return locateEntry(&SelectionSyntaxError{Definition: col, Msg: fmt.Sprintf("AS cannot rename %d columns", len(indices))})
+				}
+				csvHeader.addAlias(alias)
+				csvHeader.selectedIndices = append(csvHeader.selectedIndices, indices[0])
+				continue
+			}
+		}
+		indices, ok, err := csvHeader.resolveSelector(col)
+		if err != nil && !isUnmatchedGlob(col, err) {
+			return locateEntry(err)
+		}
+		if ok {
+			csvHeader.selectedIndices = append(csvHeader.selectedIndices, indices...)
+			continue
+		}
+		if globErr == nil {
+			globErr = err
+		}
+
+		expr, name, err := parseSelectedExpr(col, csvHeader)
+		if err != nil {
+			if globErr != nil {
+				return locateEntry(globErr)
+			}
+			return locateEntry(err)
+		}
+		i := len(csvHeader.headers) + len(csvHeader.expressions)
+		if ref, ok := expr.(columnRef); ok {
+			i = ref.index
+			if name != strings.TrimSpace(col) {
+				csvHeader.addAlias(name)
 			}
+		} else {
+			csvHeader.expressions = append(csvHeader.expressions, selectedExpr{name: name, expr: expr})
 		}
-		if unknown {
-			return fmt.Errorf("Header '%s' not found in CSV file/string", col)
+		csvHeader.selectedIndices = append(csvHeader.selectedIndices, i)
+	}
+	if excluded != nil {
+		csvHeader.exclude(excluded)
+		// Rows without fields would be written as empty lines, which
+		// cannot be read back.
+		if len(csvHeader.selectedIndices) == 0 {
+			err := &SelectionSyntaxError{Definition: selectedColumns, Msg: "every column is excluded"}
+			return locate(err, "SelectedColumns", 1, 1, 0, 0)
 		}
 	}
 	csvHeader.numSelectedColumns = len(csvHeader.selectedIndices)
 	return nil
 }
 
+// addAlias names the next selected column alias in the output header.
+func (h *CsvHeader) addAlias(alias string) {
+	if h.aliases == nil {
+		h.aliases = make(map[int]string)
+	}
+	h.aliases[len(h.selectedIndices)] = alias
+}
+
+// cutAlias splits an entry such as "3 AS id" into its selector and its
+// alias. It reports false if the entry does not end with an alias.
+func cutAlias(col string) (string, string, bool) {
+	for i := 1; i < len(col); i++ {
+		if !isSpace(col[i-1]) {
+			continue
+		}
+		p := &exprParser{definition: col, pos: i}
+		if !p.acceptKeyword("AS") {
+			continue
+		}
+		if alias, err := p.parseAlias(); err == nil && p.pos == len(col) {
+			return strings.TrimSpace(col[:i]), alias, true
+		}
+	}
+	return "", "", false
+}
+
+// exclude removes the excluded header columns from the selection, which
+// starts from all columns if it is empty.
+func (h *CsvHeader) exclude(excluded []bool) {
+	if len(h.selectedIndices) == 0 {
+		for i := range h.headers {
+			h.selectedIndices = append(h.selectedIndices, i)
+		}
+	}
+	var selected []int
+	var aliases map[int]string
+	for pos, i := range h.selectedIndices {
+		if i < len(h.headers) && excluded[i] {
+			continue
+		}
+		if alias, ok := h.aliases[pos]; ok {
+			if aliases == nil {
+				aliases = make(map[int]string)
+			}
+			aliases[len(selected)] = alias
+		}
+		selected = append(selected, i)
+	}
+	h.selectedIndices = selected
+	h.aliases = aliases
+}
+
+// resolveSelector returns the header columns selected by a name, a
+// position, a range, a glob or a regular expression. It reports false if
+// s is none of these.
+func (h *CsvHeader) resolveSelector(s string) ([]int, bool, error) {
+	s = strings.TrimSpace(s)
+	if i, ok, err := h.resolvePosition(s); ok || err != nil {
+		return []int{i}, ok, err
+	}
+
+	if from, to, ok := strings.Cut(s, ".."); ok && !strings.ContainsAny(s, "'`()") {
+		first, last := 0, len(h.headers)-1
+		var err error
+		if from = strings.TrimSpace(from); from != "" {
+			if first, ok, err = h.resolvePosition(from); err != nil || !ok {
+				return nil, false, orUnknownColumn(err, from)
+			}
+		}
+		if to = strings.TrimSpace(to); to != "" {
+			if last, ok, err = h.resolvePosition(to); err != nil || !ok {
+				return nil, false, orUnknownColumn(err, to)
+			}
+		}
+		if len(h.headers) == 0 {
+			return nil, false, &SelectionSyntaxError{Definition: s, Msg: "empty range: the header has no columns"}
+		}
+		var indices []int
+		for i := first; ; {
+			indices = append(indices, i)
+			if i == last {
+				return indices, true, nil
+			}
+			if first < last {
+				i++
+			} else {
+				i--
+			}
+		}
+	}
+
+	var match func(string) bool
+	switch {
+	case len(s) >= 2 && s[0] == '/' && s[len(s)-1] == '/':
+		pattern := s[1 : len(s)-1]
+		if h.collation == CollateNoCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, false, &SelectionSyntaxError{Definition: s, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+		// As in MATCHES filters, (?i) ignores case and names are matched
+		// unfolded, as folding may expand them.
+		match = func(name string) bool {
+			if h.collation != CollateBinary {
+				name = CollateNFC.key(name)
+			}
+			return re.MatchString(name)
+		}
+	case isGlob(s):
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, false, &SelectionSyntaxError{Definition: s, Msg: "invalid glob"}
+		}
+		pattern := h.collation.key(s)
+		match = func(name string) bool {
+			ok, _ := path.Match(pattern, h.collation.key(name))
+			return ok
+		}
+	default:
+		return nil, false, nil
+	}
+	var indices []int
+	for i, name := range h.headers {
+		if match(name) {
+			indices = append(indices, i)
+		}
+	}
+	if indices == nil {
+		return nil, false, &UnknownColumnError{Column: s}
+	}
+	return indices, true, nil
+}
+
+// resolvePosition returns the index of the column named s or at the
+// 1-based position s. It reports false if s is neither a header name nor
+// an integer.
+func (h *CsvHeader) resolvePosition(s string) (int, bool, error) {
+	if i := h.indexOf(s, h.collation); i != -1 {
+		return i, true, nil
+	}
+	if !isDigits(s) || s == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > len(h.headers) {
+		return 0, false, &UnknownColumnError{Column: s}
+	}
+	return n - 1, true, nil
+}
+
+// isUnmatchedGlob reports whether err is the error of resolveSelector for
+// a glob s that matches no column.
+func isUnmatchedGlob(s string, err error) bool {
+	_, ok := err.(*UnknownColumnError)
+	return ok && isGlob(strings.TrimSpace(s))
+}
+
+func orUnknownColumn(err error, column string) error {
+	if err != nil {
+		return err
+	}
+	return &UnknownColumnError{Column: column}
+}
+
+// isGlob reports whether s is made of name characters and at least one of
+// the wildcards "*" and "?". An expression such as "price * qty" is not a
+// glob, but "price*qty" is, and is only read as an expression if it
+// matches no column.
+func isGlob(s string) bool {
+	if !strings.ContainsAny(s, "*?") {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && r != '.' && r != '-' && !strings.ContainsRune("*?[]!", r) && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitSelection splits the selection list on the commas that are neither
+// quoted, inside parentheses nor inside a regular expression.
+func splitSelection(selectedColumns string) []string {
+	var columns []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(selectedColumns); i++ {
+		c := selectedColumns[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '`':
+			quote = c
+		case c == '/' && strings.TrimLeft(selectedColumns[start:i], " \t-") == "":
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			columns = append(columns, selectedColumns[start:i])
+			start = i + 1
+		}
+	}
+	return append(columns, selectedColumns[start:])
+}
+
+// selectColumns returns the fields of row at indexes. Fields missing from
+// a short row are null, so the result always has one field per index.
 func selectColumns(row []string, indexes []int) []string {
 	var selected []string
-	length := len(row)
 	for _, idx := range indexes {
-		if idx < length {
-			selected = append(selected, row[idx])
+		var field string
+		if idx < len(row) {
+			field = row[idx]
 		}
+		selected = append(selected, field)
 	}
 	return selected
 }
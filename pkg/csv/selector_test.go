@@ -1,7 +1,6 @@
 package csv
 
 import (
-	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -31,6 +30,129 @@ func TestParseSelectedColumns(t *testing.T) {
 			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
 			expected:        CsvHeader{headers: []string{"header1", "header2", "header3"}, selectedIndices: []int{2, 0}, numSelectedColumns: 2},
 		},
+		{
+			name:            "Select with NOCASE collation",
+			selectedColumns: "HEADER3,Header1",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}, collation: CollateNoCase},
+			expected:        CsvHeader{headers: []string{"header1", "header2", "header3"}, selectedIndices: []int{2, 0}, numSelectedColumns: 2, collation: CollateNoCase},
+		},
+		{
+			name:            "Select computed columns",
+			selectedColumns: "header1,header2*2 AS double, `header3`,coalesce(header3, 'a,b')",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected: CsvHeader{
+				headers:            []string{"header1", "header2", "header3"},
+				selectedIndices:    []int{0, 3, 2, 4},
+				numSelectedColumns: 4,
+				expressions: []selectedExpr{
+					{name: "double", expr: arithmeticExpr{op: '*', left: columnRef{index: 1}, right: literal{value: "2"}}},
+					{name: "coalesce(header3, 'a,b')", expr: callExpr{fn: functions["coalesce"], args: []valueExpr{columnRef{index: 2}, literal{value: "a,b"}}}},
+				},
+			},
+		},
+		{
+			name:            "Expression looking like a glob without matches",
+			selectedColumns: "price*qty,-price*2",
+			csvHeader:       CsvHeader{headers: []string{"price", "qty"}},
+			expected: CsvHeader{
+				headers:            []string{"price", "qty"},
+				selectedIndices:    []int{2, 3},
+				numSelectedColumns: 2,
+				expressions: []selectedExpr{
+					{name: "price*qty", expr: arithmeticExpr{op: '*', left: columnRef{index: 0}, right: columnRef{index: 1}}},
+					{name: "-price*2", expr: arithmeticExpr{op: '*', left: negateExpr{expr: columnRef{index: 0}}, right: literal{value: "2"}}},
+				},
+			},
+		},
+		{
+			name:            "Rename columns",
+			selectedColumns: "header1 AS id,header3, `header2` as `total amount`,header1",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected: CsvHeader{
+				headers:            []string{"header1", "header2", "header3"},
+				selectedIndices:    []int{0, 2, 1, 0},
+				numSelectedColumns: 4,
+				aliases:            map[int]string{0: "id", 2: "total amount"},
+			},
+		},
+		{
+			name:            "Select by position",
+			selectedColumns: "2,1",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{1, 0}, numSelectedColumns: 2},
+		},
+		{
+			name:            "Rename positions",
+			selectedColumns: "2 AS b,1 as `first column`",
+			csvHeader:       CsvHeader{headers: []string{"a", "b"}},
+			expected:        CsvHeader{headers: []string{"a", "b"}, selectedIndices: []int{1, 0}, numSelectedColumns: 2, aliases: map[int]string{0: "b", 1: "first column"}},
+		},
+		{
+			name:            "Select range of names",
+			selectedColumns: "amount_1..name",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{1, 2, 3}, numSelectedColumns: 3},
+		},
+		{
+			name:            "Select reversed range of positions",
+			selectedColumns: "4..2",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{3, 2, 1}, numSelectedColumns: 3},
+		},
+		{
+			name:            "Select open range",
+			selectedColumns: "name..,..id",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{3, 4, 0}, numSelectedColumns: 3},
+		},
+		{
+			name:            "Select glob",
+			selectedColumns: "id,amount_?",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{0, 1, 2}, numSelectedColumns: 3},
+		},
+		{
+			name:            "Select regular expression",
+			selectedColumns: "/^amount_[0-9]{1,2}$/,id",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{1, 2, 0}, numSelectedColumns: 3},
+		},
+		{
+			name:            "Exclude columns",
+			selectedColumns: "-amount_*,-2",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{0, 3}, numSelectedColumns: 2},
+		},
+		{
+			name:            "Exclude from selection",
+			selectedColumns: "5..1,-/_[0-9]/",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{4, 3, 0}, numSelectedColumns: 3},
+		},
+		{
+			name:            "Exclusion keeps aliases",
+			selectedColumns: "id AS key,amount_1,name AS label,-amount_1",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{0, 3}, numSelectedColumns: 2, aliases: map[int]string{0: "key", 1: "label"}},
+		},
+		{
+			name:            "Names take precedence over selectors",
+			selectedColumns: "2,a*b",
+			csvHeader:       CsvHeader{headers: []string{"a*b", "2"}},
+			expected:        CsvHeader{headers: []string{"a*b", "2"}, selectedIndices: []int{1, 0}, numSelectedColumns: 2},
+		},
+		{
+			name:            "Select glob with NOCASE collation",
+			selectedColumns: "AMOUNT_*",
+			csvHeader:       CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, collation: CollateNoCase},
+			expected:        CsvHeader{headers: []string{"id", "amount_1", "amount_2", "name", "amount_total"}, selectedIndices: []int{1, 2, 4}, numSelectedColumns: 3, collation: CollateNoCase},
+		},
+		{
+			name:            "Select regular expression with NOCASE collation",
+			selectedColumns: "/^STRA\u00dfE$/",
+			csvHeader:       CsvHeader{headers: []string{"id", "Stra\u00dfe"}, collation: CollateNoCase},
+			expected:        CsvHeader{headers: []string{"id", "Stra\u00dfe"}, selectedIndices: []int{1}, numSelectedColumns: 1, collation: CollateNoCase},
+		},
 		{
 			name:            "Select single column",
 			selectedColumns: "header2",
@@ -59,13 +181,103 @@ func TestParseSelectedUnknownColumns(t *testing.T) {
 			name:            "unknown single column",
 			selectedColumns: "header0",
 			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
-			expected:        fmt.Errorf("Header 'header0' not found in CSV file/string"),
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Column: "header0"},
+		},
+		{
+			name:            "Select column differing in case",
+			selectedColumns: "HEADER1",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Column: "HEADER1"},
+		},
+		{
+			name:            "Unknown column in expression",
+			selectedColumns: "header1,upper(header4)",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 15, Offset: 14, Field: 2}, Column: "header4"},
+		},
+		{
+			name:            "Invalid expression",
+			selectedColumns: "header1,header2 +",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 18, Offset: 17, Field: 2}, Definition: "header2 +", Msg: "missing operand"},
+		},
+		{
+			name:            "Position out of range",
+			selectedColumns: "header1,4",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 9, Offset: 8, Field: 2}, Column: "4"},
+		},
+		{
+			name:            "Unknown range end",
+			selectedColumns: "header1..header4",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Column: "header4"},
+		},
+		{
+			name:            "Open range of an empty header",
+			selectedColumns: "..",
+			csvHeader:       CsvHeader{},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Definition: "..", Msg: "empty range: the header has no columns"},
+		},
+		{
+			name:            "Excluded open range of an empty header",
+			selectedColumns: "-..",
+			csvHeader:       CsvHeader{},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Definition: "..", Msg: "empty range: the header has no columns"},
+		},
+		{
+			name:            "Rename several columns",
+			selectedColumns: "header1,3..2 AS h",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 9, Offset: 8, Field: 2}, Definition: "3..2 AS h", Msg: "AS cannot rename 2 columns"},
+		},
+		{
+			name:            "Glob without matches",
+			selectedColumns: "amount_*",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Column: "amount_*"},
+		},
+		{
+			name:            "Glob without matches that is no expression",
+			selectedColumns: "header1,amount_*",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 9, Offset: 8, Field: 2}, Column: "amount_*"},
+		},
+		{
+			name:            "Excluded glob without matches",
+			selectedColumns: "-amount_?",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Column: "amount_?"},
+		},
+		{
+			name:            "Invalid regular expression",
+			selectedColumns: "/a(/",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1, Field: 1}, Definition: "/a(/", Msg: "invalid regular expression: error parsing regexp: missing closing ): `a(`"},
+		},
+		{
+			name:            "Exclude every selected column",
+			selectedColumns: "header1,-header1",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1}, Definition: "header1,-header1", Msg: "every column is excluded"},
+		},
+		{
+			name:            "Exclude the only column",
+			selectedColumns: "-header1",
+			csvHeader:       CsvHeader{headers: []string{"header1"}},
+			expected:        &SelectionSyntaxError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 1}, Definition: "-header1", Msg: "every column is excluded"},
+		},
+		{
+			name:            "Exclude unknown column",
+			selectedColumns: "-header4",
+			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 2, Offset: 1, Field: 1}, Column: "header4"},
 		},
 		{
 			name:            "Select non-existent column",
 			selectedColumns: "header1,header4",
 			csvHeader:       CsvHeader{headers: []string{"header1", "header2", "header3"}},
-			expected:        fmt.Errorf("Header 'header4' not found in CSV file/string"),
+			expected:        &UnknownColumnError{Position: Position{Source: "SelectedColumns", Line: 1, Column: 9, Offset: 8, Field: 2}, Column: "header4"},
 		},
 	}
 
@@ -118,7 +330,7 @@ func TestSelectColumns(t *testing.T) {
 			name:     "Out of range index",
 			row:      []string{"1", "2", "3"},
 			indices:  []int{0, 3},
-			expected: []string{"1"},
+			expected: []string{"1", ""},
 		},
 	}
 
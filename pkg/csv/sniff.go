@@ -0,0 +1,191 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encodings reported by Sniff. Input in any of them is decoded to UTF-8
+// when Options.DetectDialect is set.
+const (
+	EncodingUTF8        = "UTF-8"
+	EncodingUTF8BOM     = "UTF-8 with BOM"
+	EncodingUTF16LE     = "UTF-16LE"
+	EncodingUTF16BE     = "UTF-16BE"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// SniffResult describes the shape of a CSV document as guessed by Sniff.
+type SniffResult struct {
+	// Dialect holds the delimiter, quote and escape of the records, and
+	// the line terminator of the first record, if any. The delimiter and
+	// quote are always set, even when they are the RFC 4180 defaults.
+	Dialect Dialect
+	// Encoding is one of the Encoding constants. Input that is not valid
+	// UTF-8 and has no byte order mark is assumed to be windows-1252.
+	Encoding string
+	// HasHeader reports whether the first record looks like a header
+	// rather than data.
+	HasHeader bool
+	// ColumnTypes holds the type inferred from the sampled values of each
+	// column, excluding the header. Columns whose values are all empty are
+	// TypeAuto.
+	ColumnTypes []ColumnType
+}
+
+// Sniff inspects the first records of r and guesses its dialect, encoding,
+// column types and whether it has a header. It reads at most 64 KiB.
+func Sniff(r io.Reader) (SniffResult, error) {
+	result, _, err := sniff(r)
+	return result, err
+}
+
+// sniff is like Sniff but peeks at r instead of consuming it, and returns
+// a reader of the whole of r decoded to UTF-8.
+func sniff(r io.Reader) (SniffResult, io.Reader, error) {
+	decoded, encoding, err := decodeInput(bufio.NewReaderSize(r, sniffSize))
+	if err != nil {
+		return SniffResult{}, nil, err
+	}
+	buffered := bufio.NewReaderSize(decoded, sniffSize)
+	sample, err := buffered.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return SniffResult{}, nil, err
+	}
+	complete := err == io.EOF
+	result := SniffResult{Dialect: detectDialect(sample, complete), Encoding: encoding}
+	if i := bytes.IndexByte(sample, '\n'); i > 0 && sample[i-1] == '\r' {
+		result.Dialect.LineTerminator = "\r\n"
+	}
+	result.Dialect.Delimiter = rune(result.Dialect.delimiter())
+	result.Dialect.Quote = rune(result.Dialect.quote())
+
+	if !complete {
+		sample = sample[:bytes.LastIndexByte(sample, '\n')+1]
+	}
+	reader := newRecordReader(bytes.NewReader(sample), result.Dialect)
+	var records [][]string
+	for len(records) <= sniffLines {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return result, buffered, nil
+	}
+	result.HasHeader = hasHeader(records)
+	data := records
+	if result.HasHeader {
+		data = records[1:]
+	}
+	result.ColumnTypes = make([]ColumnType, len(records[0]))
+	for i := range result.ColumnTypes {
+		result.ColumnTypes[i] = columnType(data, i)
+	}
+	return result, buffered, nil
+}
+
+// decodeInput detects the encoding of r from its byte order mark or, if
+// there is none, from whether its first bytes are valid UTF-8, and returns
+// r decoded to UTF-8 without the byte order mark.
+func decodeInput(r *bufio.Reader) (io.Reader, string, error) {
+	sample, err := r.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8BOM.NewDecoder().Reader(r), EncodingUTF8BOM, nil
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Reader(r), EncodingUTF16LE, nil
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Reader(r), EncodingUTF16BE, nil
+	}
+	if err == nil {
+		// The sample may end in the middle of a character.
+		for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(sample[i]) {
+				if !utf8.FullRune(sample[i:]) {
+					sample = sample[:i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(sample) {
+		return r, EncodingUTF8, nil
+	}
+	return charmap.Windows1252.NewDecoder().Reader(r), EncodingWindows1252, nil
+}
+
+// hasHeader guesses whether the first record is a header by comparing its
+// fields with the values below them. A field votes for a header if its
+// column holds numbers or timestamps but it does not, or if the column's
+// values all have one length that the field does not have; it votes
+// against if it is a number or timestamp like the values. Without any vote
+// the first record is assumed to be a header.
+func hasHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return true
+	}
+	votes := 0
+	for i, field := range records[0] {
+		switch t := columnType(records[1:], i); {
+		case t == TypeAuto:
+		case t != TypeString:
+			if t.isValid(field) {
+				votes--
+			} else {
+				votes++
+			}
+		default:
+			// A header field as long as codes such as "col1" above "l1c1"
+			// says nothing, so only a different length votes.
+			if length, ok := commonLength(records[1:], i); ok && utf8.RuneCountInString(field) != length {
+				votes++
+			}
+		}
+	}
+	return votes >= 0
+}
+
+// columnType returns the type of the non-empty values of column i of
+// records, or TypeAuto if there are none.
+func columnType(records [][]string, i int) ColumnType {
+	t := TypeAuto
+	for _, record := range records {
+		if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			continue
+		}
+		if v := inferType(record[i]); t == TypeAuto {
+			t = v
+		} else {
+			t = commonType(t, v)
+		}
+	}
+	return t
+}
+
+// commonLength returns the length in characters shared by all the values
+// of column i of records. It reports false if the lengths differ.
+func commonLength(records [][]string, i int) (int, bool) {
+	length := -1
+	for _, record := range records {
+		if i >= len(record) {
+			continue
+		}
+		n := utf8.RuneCountInString(record[i])
+		if length != -1 && n != length {
+			return 0, false
+		}
+		length = n
+	}
+	return length, length != -1
+}
@@ -0,0 +1,119 @@
+package csv
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected SniffResult
+	}{
+		{
+			name:  "Comma-separated with header",
+			input: []byte("id,name,price,created\n1,Ação,2.50,2024-01-02\n2,Feijão,3,2024-02-03\n"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: ',', Quote: '"'},
+				Encoding:    EncodingUTF8,
+				HasHeader:   true,
+				ColumnTypes: []ColumnType{TypeInteger, TypeString, TypeDecimal, TypeTimestamp},
+			},
+		},
+		{
+			name:  "Headerless semicolons with CRLF",
+			input: []byte("1;2,50;x\r\n2;3,75;\r\n"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: ';', Quote: '"', LineTerminator: "\r\n"},
+				Encoding:    EncodingUTF8,
+				HasHeader:   false,
+				ColumnTypes: []ColumnType{TypeInteger, TypeString, TypeString},
+			},
+		},
+		{
+			name:  "Fixed-length codes below a header",
+			input: []byte("region\tcity\nBR01\tBelém\nBR02\tSão Paulo\n"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: '\t', Quote: '"'},
+				Encoding:    EncodingUTF8,
+				HasHeader:   true,
+				ColumnTypes: []ColumnType{TypeString, TypeString},
+			},
+		},
+		{
+			name:  "Codes as long as the header",
+			input: []byte("col1,col2,col3\nl1c1,l1c2,l1c3\nl2c1,l2c2,l2c3\n"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: ',', Quote: '"'},
+				Encoding:    EncodingUTF8,
+				HasHeader:   true,
+				ColumnTypes: []ColumnType{TypeString, TypeString, TypeString},
+			},
+		},
+		{
+			name:  "UTF-8 byte order mark",
+			input: []byte("\xEF\xBB\xBFa|b\n1|\n"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: '|', Quote: '"'},
+				Encoding:    EncodingUTF8BOM,
+				HasHeader:   true,
+				ColumnTypes: []ColumnType{TypeInteger, TypeAuto},
+			},
+		},
+		{
+			name:  "UTF-16LE",
+			input: []byte("\xFF\xFEa\x00,\x00b\x00\n\x001\x00,\x002\x00\n\x00"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: ',', Quote: '"'},
+				Encoding:    EncodingUTF16LE,
+				HasHeader:   true,
+				ColumnTypes: []ColumnType{TypeInteger, TypeInteger},
+			},
+		},
+		{
+			name:  "Windows-1252",
+			input: []byte("nome;valor\nA\xE7\xE3o;1\n"),
+			expected: SniffResult{
+				Dialect:     Dialect{Delimiter: ';', Quote: '"'},
+				Encoding:    EncodingWindows1252,
+				HasHeader:   true,
+				ColumnTypes: []ColumnType{TypeString, TypeInteger},
+			},
+		},
+		{
+			name:     "Empty input",
+			input:    []byte(""),
+			expected: SniffResult{Dialect: Dialect{Delimiter: ',', Quote: '"'}, Encoding: EncodingUTF8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Sniff(bytes.NewReader(tt.input))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestSniffLargeInput(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("id;name\n")
+	for b.Len() < 2*sniffSize {
+		b.WriteString("12345;Ação\n")
+	}
+	result, err := Sniff(strings.NewReader(b.String()))
+	assert.Nil(t, err)
+	assert.Equal(t, SniffResult{Dialect: Dialect{Delimiter: ';', Quote: '"'}, Encoding: EncodingUTF8, HasHeader: true, ColumnTypes: []ColumnType{TypeInteger, TypeString}}, result)
+}
+
+func TestProcessDetectsEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	err := Process(context.Background(), bytes.NewReader([]byte("nome;valor\nA\xE7\xE3o;1\nFeij\xE3o;2\n")), &buf, Options{RowFilterDefinitions: "valor=1", DetectDialect: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "nome,valor\nAção,1\n", buf.String())
+}
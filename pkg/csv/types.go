@@ -0,0 +1,298 @@
+package csv
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType controls how the values of a column are compared by filters.
+type ColumnType int
+
+const (
+	// TypeAuto infers the type from the literal values of a filter once,
+	// when it is parsed: numbers are compared as decimals, timestamps
+	// chronologically and anything else as strings, and values of the
+	// column that are not of the inferred type fail the comparison.
+	// Zero-padded numbers such as "01234" are compared as strings by =,
+	// != and IN, so that identifiers such as zip codes match exactly. Two
+	// columns compared with each other are typed by their values instead:
+	// two integers are compared as integers, two numbers as decimals, two
+	// timestamps chronologically and anything else as strings.
+	TypeAuto ColumnType = iota
+	TypeString
+	TypeInteger
+	TypeDecimal
+	TypeTimestamp
+)
+
+// timestampLayouts are tried in order when parsing a timestamp. Slashed
+// dates are day-first.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02/01/2006 15:04:05",
+	"02/01/2006",
+}
+
+func (t ColumnType) String() string {
+	switch t {
+	case TypeAuto:
+		return "auto"
+	case TypeString:
+		return "string"
+	case TypeInteger:
+		return "integer"
+	case TypeDecimal:
+		return "decimal"
+	case TypeTimestamp:
+		return "timestamp"
+	default:
+		return "ColumnType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// isValid reports whether s can be compared as a value of type t.
+func (t ColumnType) isValid(s string) bool {
+	switch t {
+	case TypeInteger:
+		_, ok := parseInteger(s)
+		return ok
+	case TypeDecimal:
+		_, ok := parseDecimal(s)
+		return ok
+	case TypeTimestamp:
+		_, ok := parseTimestamp(s)
+		return ok
+	default:
+		return true
+	}
+}
+
+// inferType returns the narrowest type s can be compared as.
+func inferType(s string) ColumnType {
+	if _, ok := parseInteger(s); ok {
+		return TypeInteger
+	}
+	if _, ok := parseDecimal(s); ok {
+		return TypeDecimal
+	}
+	if _, ok := parseTimestamp(s); ok {
+		return TypeTimestamp
+	}
+	return TypeString
+}
+
+// literalType returns the type a column is compared with the literal
+// values as: decimal if they are all numbers, timestamp if they are all
+// timestamps and string otherwise. It returns TypeAuto if there are no
+// values.
+func literalType(values []string) ColumnType {
+	t := TypeAuto
+	for _, value := range values {
+		valueType := inferType(value)
+		if valueType == TypeInteger {
+			valueType = TypeDecimal
+		}
+		if t != TypeAuto && t != valueType {
+			return TypeString
+		}
+		t = valueType
+	}
+	return t
+}
+
+// equalityType is like literalType for equality and set membership, but
+// keeps zero-padded numbers such as the zip code "01234" as strings, so
+// that they do not equal "1234".
+func equalityType(values []string) ColumnType {
+	t := literalType(values)
+	if t == TypeDecimal {
+		for _, value := range values {
+			if hasLeadingZero(value) {
+				return TypeString
+			}
+		}
+	}
+	return t
+}
+
+// hasLeadingZero reports whether the integer part of the number s has a
+// zero followed by more digits.
+func hasLeadingZero(s string) bool {
+	s = strings.TrimLeft(strings.TrimSpace(s), "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
+
+// commonType returns the type two values of the given inferred types are
+// compared as.
+func commonType(a, b ColumnType) ColumnType {
+	switch {
+	case a == b:
+		return a
+	case (a == TypeInteger || a == TypeDecimal) && (b == TypeInteger || b == TypeDecimal):
+		return TypeDecimal
+	default:
+		return TypeString
+	}
+}
+
+// compareValues compares a and b as values of type t, using collation c
+// for strings, and returns -1, 0 or +1. It reports false if either value is
+// not valid for t.
+func compareValues(a, b string, t ColumnType, c Collation) (int, bool) {
+	if t == TypeAuto {
+		t = commonType(inferType(a), inferType(b))
+	}
+	switch t {
+	case TypeInteger:
+		x, okX := parseInteger(a)
+		y, okY := parseInteger(b)
+		if !okX || !okY {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case TypeDecimal:
+		x, okX := parseDecimal(a)
+		y, okY := parseDecimal(b)
+		if !okX || !okY {
+			return 0, false
+		}
+		return x.compare(y), true
+	case TypeTimestamp:
+		x, okX := parseTimestamp(a)
+		y, okY := parseTimestamp(b)
+		if !okX || !okY {
+			return 0, false
+		}
+		return x.Compare(y), true
+	default:
+		return c.compare(a, b), true
+	}
+}
+
+// canonicalValue returns a key under which values of type t that compare
+// equal under collation c are identical, so that IN lists can be looked up
+// in a hash set. It reports false if s is not valid for t.
+func canonicalValue(s string, t ColumnType, c Collation) (string, bool) {
+	if t == TypeAuto {
+		t = inferType(s)
+	}
+	switch t {
+	case TypeInteger, TypeDecimal:
+		if t == TypeInteger && !t.isValid(s) {
+			return "", false
+		}
+		d, ok := parseDecimal(s)
+		if !ok {
+			return "", false
+		}
+		return d.String(), true
+	case TypeTimestamp:
+		ts, ok := parseTimestamp(s)
+		if !ok {
+			return "", false
+		}
+		return ts.UTC().Format(time.RFC3339Nano), true
+	default:
+		return c.key(s), true
+	}
+}
+
+func parseInteger(s string) (int64, bool) {
+	i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return i, err == nil
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// decimal is an exact fixed-point number of arbitrary precision, kept as
+// its digits with leading integer and trailing fraction zeros removed.
+type decimal struct {
+	negative bool
+	integer  string
+	fraction string
+}
+
+func parseDecimal(s string) (decimal, bool) {
+	s = strings.TrimSpace(s)
+	var d decimal
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		d.negative = s[0] == '-'
+		s = s[1:]
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+	if integer == "" && fraction == "" || !isDigits(integer) || !isDigits(fraction) {
+		return decimal{}, false
+	}
+	d.integer = strings.TrimLeft(integer, "0")
+	d.fraction = strings.TrimRight(fraction, "0")
+	if d.integer == "" && d.fraction == "" {
+		d.negative = false
+	}
+	return d, true
+}
+
+func (d decimal) String() string {
+	s := d.integer
+	if s == "" {
+		s = "0"
+	}
+	if d.fraction != "" {
+		s += "." + d.fraction
+	}
+	if d.negative {
+		s = "-" + s
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d decimal) compare(o decimal) int {
+	if d.negative != o.negative {
+		if d.negative {
+			return -1
+		}
+		return 1
+	}
+	c := len(d.integer) - len(o.integer)
+	if c == 0 {
+		c = strings.Compare(d.integer, o.integer)
+	}
+	if c == 0 {
+		c = strings.Compare(d.fraction, o.fraction)
+	}
+	switch {
+	case c < 0 && d.negative, c > 0 && !d.negative:
+		return 1
+	case c == 0:
+		return 0
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,137 @@
+package csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		a          string
+		b          string
+		columnType ColumnType
+		expected   int
+		valid      bool
+	}{
+		{name: "Inferred integers", a: "9", b: "100", columnType: TypeAuto, expected: -1, valid: true},
+		{name: "Inferred negative integers", a: "-10", b: "-9", columnType: TypeAuto, expected: -1, valid: true},
+		{name: "Inferred decimals", a: "10.5", b: "9.75", columnType: TypeAuto, expected: 1, valid: true},
+		{name: "Integer and decimal", a: "2", b: "2.000", columnType: TypeAuto, expected: 0, valid: true},
+		{name: "Large decimals", a: "123456789012345678901234567890.1", b: "123456789012345678901234567890.09", columnType: TypeAuto, expected: 1, valid: true},
+		{name: "Inferred ISO dates", a: "2024-01-31", b: "2024-02-01", columnType: TypeAuto, expected: -1, valid: true},
+		{name: "Inferred day-first dates", a: "01/02/2024", b: "31/01/2024", columnType: TypeAuto, expected: 1, valid: true},
+		{name: "Inferred timestamps", a: "2024-01-01T10:00:00Z", b: "2024-01-01T09:00:00-03:00", columnType: TypeAuto, expected: -1, valid: true},
+		{name: "Number and text", a: "9", b: "abc", columnType: TypeAuto, expected: -1, valid: true},
+		{name: "Inferred strings", a: "l2c1", b: "l1c1", columnType: TypeAuto, expected: 1, valid: true},
+		{name: "Declared string", a: "9", b: "100", columnType: TypeString, expected: 1, valid: true},
+		{name: "Declared integer", a: "9", b: "100", columnType: TypeInteger, expected: -1, valid: true},
+		{name: "Declared integer with invalid value", a: "9.5", b: "100", columnType: TypeInteger, valid: false},
+		{name: "Declared decimal", a: "-0.0", b: "0", columnType: TypeDecimal, expected: 0, valid: true},
+		{name: "Declared decimal with invalid value", a: "n/a", b: "0", columnType: TypeDecimal, valid: false},
+		{name: "Declared timestamp", a: "2024-03-01 12:00:00", b: "2024-03-01", columnType: TypeTimestamp, expected: 1, valid: true},
+		{name: "Declared timestamp with invalid value", a: "tomorrow", b: "2024-03-01", columnType: TypeTimestamp, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, valid := compareValues(tt.a, tt.b, tt.columnType, CollateBinary)
+			assert.Equal(t, tt.valid, valid)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestLiteralType(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected ColumnType
+	}{
+		{name: "No values", values: nil, expected: TypeAuto},
+		{name: "Integers", values: []string{"100", "-7"}, expected: TypeDecimal},
+		{name: "Integer and decimal", values: []string{"1", "2.5"}, expected: TypeDecimal},
+		{name: "Dates", values: []string{"2024-01-31", "01/02/2024"}, expected: TypeTimestamp},
+		{name: "Number and date", values: []string{"1", "2024-01-31"}, expected: TypeString},
+		{name: "Text", values: []string{"N/A"}, expected: TypeString},
+		{name: "Empty value", values: []string{""}, expected: TypeString},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, literalType(tt.values))
+		})
+	}
+}
+
+func TestEqualityType(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected ColumnType
+	}{
+		{name: "Numbers", values: []string{"0", "0.5", "-10"}, expected: TypeDecimal},
+		{name: "Zero-padded number", values: []string{"1", "01234"}, expected: TypeString},
+		{name: "Zero-padded negative number", values: []string{"-007"}, expected: TypeString},
+		{name: "Dates", values: []string{"2024-01-31"}, expected: TypeTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, equalityType(tt.values))
+		})
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected decimal
+		valid    bool
+	}{
+		{value: "0012.3400", expected: decimal{integer: "12", fraction: "34"}, valid: true},
+		{value: "-.5", expected: decimal{negative: true, fraction: "5"}, valid: true},
+		{value: "+7.", expected: decimal{integer: "7"}, valid: true},
+		{value: "-0", expected: decimal{}, valid: true},
+		{value: ".", valid: false},
+		{value: "1e3", valid: false},
+		{value: "1.2.3", valid: false},
+		{value: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, valid := parseDecimal(tt.value)
+			assert.Equal(t, tt.valid, valid)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestCanonicalValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		columnType ColumnType
+		expected   string
+		valid      bool
+	}{
+		{name: "Inferred integer", value: "0042", columnType: TypeAuto, expected: "42", valid: true},
+		{name: "Inferred decimal", value: "-1.50", columnType: TypeAuto, expected: "-1.5", valid: true},
+		{name: "Inferred timestamp", value: "2024-01-01T00:00:00-03:00", columnType: TypeAuto, expected: "2024-01-01T03:00:00Z", valid: true},
+		{name: "Inferred string", value: " abc ", columnType: TypeAuto, expected: " abc ", valid: true},
+		{name: "Declared string", value: "0042", columnType: TypeString, expected: "0042", valid: true},
+		{name: "Declared integer", value: "+7", columnType: TypeInteger, expected: "7", valid: true},
+		{name: "Declared integer with decimal value", value: "7.5", columnType: TypeInteger, valid: false},
+		{name: "Declared decimal", value: "7", columnType: TypeDecimal, expected: "7", valid: true},
+		{name: "Declared timestamp with invalid value", value: "7", columnType: TypeTimestamp, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, valid := canonicalValue(tt.value, tt.columnType, CollateBinary)
+			assert.Equal(t, tt.valid, valid)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
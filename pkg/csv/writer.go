@@ -0,0 +1,101 @@
+package csv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrUnencodable is returned when writing a field that contains the
+// delimiter or a line break in a dialect that neither quotes nor escapes.
+var ErrUnencodable = errors.New("field cannot be written without quote or escape")
+
+// recordWriter writes records in a Dialect, by default RFC 4180, quoting
+// only the fields that contain a delimiter, a quote, an escape or a line
+// break.
+type recordWriter struct {
+	w              *bufio.Writer
+	delimiter      byte
+	quote          byte
+	escape         byte
+	lineTerminator string
+	// special holds the bytes that make a field need quoting or escaping.
+	special string
+}
+
+func newRecordWriter(w io.Writer, d Dialect) *recordWriter {
+	writer := &recordWriter{
+		w:              bufio.NewWriter(w),
+		delimiter:      d.delimiter(),
+		quote:          d.quote(),
+		escape:         d.escape(),
+		lineTerminator: d.lineTerminator(),
+	}
+	writer.special = string([]byte{writer.delimiter, '\r', '\n'})
+	for _, c := range []byte{writer.quote, writer.escape} {
+		if c != 0 {
+			writer.special += string(c)
+		}
+	}
+	return writer
+}
+
+// Write writes record followed by the line terminator. A record of a
+// single empty field is written as an empty quoted field, since an empty
+// line is skipped when reading.
+func (w *recordWriter) Write(record []string) error {
+	if len(record) == 1 && record[0] == "" && w.quote != 0 {
+		_, err := w.w.WriteString(string([]byte{w.quote, w.quote}) + w.lineTerminator)
+		return err
+	}
+	for i, field := range record {
+		if i > 0 {
+			if err := w.w.WriteByte(w.delimiter); err != nil {
+				return err
+			}
+		}
+		if !strings.ContainsAny(field, w.special) {
+			if _, err := w.w.WriteString(field); err != nil {
+				return err
+			}
+			continue
+		}
+		if w.quote == 0 && w.escape == 0 {
+			return fmt.Errorf("%w: %q", ErrUnencodable, field)
+		}
+		if _, err := w.w.WriteString(w.quoteField(field)); err != nil {
+			return err
+		}
+	}
+	_, err := w.w.WriteString(w.lineTerminator)
+	return err
+}
+
+// quoteField encloses field in quotes, escaping the quotes and escapes
+// within it. Without quoting it escapes every special byte instead.
+func (w *recordWriter) quoteField(field string) string {
+	var b strings.Builder
+	if w.quote != 0 {
+		b.WriteByte(w.quote)
+	}
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		switch {
+		case w.escape != 0 && (c == w.escape || c == w.quote || w.quote == 0 && strings.IndexByte(w.special, c) >= 0):
+			b.WriteByte(w.escape)
+		case w.escape == 0 && w.quote != 0 && c == w.quote:
+			b.WriteByte(w.quote)
+		}
+		b.WriteByte(c)
+	}
+	if w.quote != 0 {
+		b.WriteByte(w.quote)
+	}
+	return b.String()
+}
+
+func (w *recordWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,138 @@
+package csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestRecordWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  [][]string
+		dialect  Dialect
+		expected string
+	}{
+		{
+			name:     "Plain fields",
+			records:  [][]string{{"a", "b"}, {"1", "2"}},
+			expected: "a,b\n1,2\n",
+		},
+		{
+			name:     "Fields that need quotes",
+			records:  [][]string{{"a,b", "say \"hi\"", "line1\nline2"}},
+			expected: "\"a,b\",\"say \"\"hi\"\"\",\"line1\nline2\"\n",
+		},
+		{
+			name:     "Empty record",
+			records:  [][]string{{}},
+			expected: "\n",
+		},
+		{
+			name:     "Single empty field",
+			records:  [][]string{{"h"}, {""}, {"", ""}},
+			expected: "h\n\"\"\n,\n",
+		},
+		{
+			name:     "Single empty field with another quote",
+			records:  [][]string{{""}},
+			dialect:  Dialect{Quote: '\'', LineTerminator: "\r\n"},
+			expected: "''\r\n",
+		},
+		{
+			name:     "Spaces are not quoted",
+			records:  [][]string{{" a ", ""}},
+			expected: " a ,\n",
+		},
+		{
+			name:     "Semicolon delimiter and CRLF",
+			records:  [][]string{{"a;b", "c,d"}, {"1", "2"}},
+			dialect:  Dialect{Delimiter: ';', LineTerminator: "\r\n"},
+			expected: "\"a;b\";c,d\r\n1;2\r\n",
+		},
+		{
+			name:     "Backslash escape",
+			records:  [][]string{{"say \"hi\"", "a\\b", "plain"}},
+			dialect:  Dialect{Delimiter: '|', Quote: '\'', Escape: '\\'},
+			expected: "say \"hi\"|'a\\\\b'|plain\n",
+		},
+		{
+			name:     "Escaped single quote",
+			records:  [][]string{{"it's"}},
+			dialect:  Dialect{Quote: '\'', Escape: '\\'},
+			expected: "'it\\'s'\n",
+		},
+		{
+			name:     "TSV",
+			records:  [][]string{{"a,b", "C:\\temp", "\"q\""}},
+			dialect:  TSV,
+			expected: "a,b\tC:\\temp\t\"q\"\n",
+		},
+		{
+			name:     "Tab-separated with backslash escape",
+			records:  [][]string{{"a\tb", "line1\nline2", "\"q\""}},
+			dialect:  Dialect{Delimiter: '\t', Quote: NoQuote, Escape: '\\'},
+			expected: "a\\\tb\tline1\\\nline2\t\"q\"\n",
+		},
+		{
+			name:     "No quoting nor escaping",
+			records:  [][]string{{"a,b \"c\"", "d"}},
+			dialect:  Dialect{Delimiter: '|', Quote: NoQuote},
+			expected: "a,b \"c\"|d\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := newRecordWriter(&buf, tt.dialect)
+			for _, record := range tt.records {
+				assert.Nil(t, writer.Write(record))
+			}
+			assert.Nil(t, writer.Flush())
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestRecordWriterUnencodable(t *testing.T) {
+	for _, field := range []string{"a|b", "line1\nline2", "a\rb"} {
+		t.Run(field, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := newRecordWriter(&buf, Dialect{Delimiter: '|', Quote: NoQuote})
+			err := writer.Write([]string{"x", field})
+			assert.ErrorIs(t, err, ErrUnencodable)
+		})
+	}
+	for _, field := range []string{"a\tb", "line1\nline2"} {
+		t.Run("TSV "+field, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := newRecordWriter(&buf, TSV)
+			err := writer.Write([]string{"x", field})
+			assert.ErrorIs(t, err, ErrUnencodable)
+		})
+	}
+}
+
+func TestRecordWriterRoundTrip(t *testing.T) {
+	records := [][]string{{"h"}, {""}, {"a,b"}, {""}, {"say \"hi\""}, {"line1\nline2"}}
+	var buf bytes.Buffer
+	writer := newRecordWriter(&buf, Dialect{})
+	for _, record := range records {
+		assert.Nil(t, writer.Write(record))
+	}
+	assert.Nil(t, writer.Flush())
+
+	reader := newRecordReader(&buf, Dialect{})
+	var read [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		read = append(read, record)
+	}
+	assert.Equal(t, records, read)
+}